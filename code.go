@@ -0,0 +1,121 @@
+package kv
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Predefined codes for classifying errors. See Error.WithCode.
+const (
+	NotFound     = "not_found"    // resource does not exist
+	Invalid      = "invalid"      // request or input is not valid
+	Conflict     = "conflict"     // conflicts with the current state
+	Unauthorized = "unauthorized" // caller is not authorized
+	Internal     = "internal"     // unexpected internal error
+	Unavailable  = "unavailable"  // temporarily unavailable, try again later
+)
+
+// codeKey is the key used to render the error code.
+const codeKey = "code"
+
+// httpStatuses maps the predefined codes to HTTP status codes.
+var httpStatuses = map[string]int{
+	NotFound:     http.StatusNotFound,
+	Invalid:      http.StatusBadRequest,
+	Conflict:     http.StatusConflict,
+	Unauthorized: http.StatusUnauthorized,
+	Internal:     http.StatusInternalServerError,
+	Unavailable:  http.StatusServiceUnavailable,
+}
+
+// exitCodes maps the predefined codes to process exit codes,
+// based on the BSD sysexits.h conventions.
+var exitCodes = map[string]int{
+	NotFound:     66, // EX_NOINPUT
+	Invalid:      65, // EX_DATAERR
+	Conflict:     75, // EX_TEMPFAIL
+	Unauthorized: 77, // EX_NOPERM
+	Internal:     70, // EX_SOFTWARE
+	Unavailable:  69, // EX_UNAVAILABLE
+}
+
+// Code returns the code associated with err. If err does not have
+// a code, the code of the nearest error that it wraps is returned.
+// Code returns a blank string if no error in the chain has a code.
+//
+// An error returned by Join that combines only one error has the code
+// of that error. An error that combines more than one error does not
+// have a code unless one is set with its WithCode method.
+func Code(err error) string {
+	for ; err != nil; err = unwrapOne(err) {
+		if e := asErrorT(err); e != nil && e.code != "" {
+			return e.code
+		}
+	}
+	return ""
+}
+
+// PublicMessage returns the public message associated with err, which
+// is safe to show to end users. If err does not have a public message, the
+// public message of the nearest error that it wraps is returned.
+// PublicMessage returns a blank string if no error in the chain has
+// a public message. As for Code, an error returned by Join that combines
+// only one error has the public message of that error.
+func PublicMessage(err error) string {
+	for ; err != nil; err = unwrapOne(err) {
+		if e := asErrorT(err); e != nil && e.public != "" {
+			return e.public
+		}
+	}
+	return ""
+}
+
+// unwrapOne returns the error wrapped by err. If err was returned by
+// Join and combines only one error, that error is returned.
+func unwrapOne(err error) error {
+	if e := asErrorT(err); e != nil && len(e.errs) == 1 {
+		return e.errs[0]
+	}
+	return errors.Unwrap(err)
+}
+
+// HTTPStatus returns the HTTP status code appropriate for err, based
+// on its code. It returns http.StatusOK if err is nil, and
+// http.StatusInternalServerError if err does not have one of the
+// predefined codes.
+func HTTPStatus(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	if status, ok := httpStatuses[Code(err)]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// ExitCode returns the process exit code appropriate for err, based
+// on its code. It returns zero if err is nil, and 1 if err does not
+// have one of the predefined codes.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if code, ok := exitCodes[Code(err)]; ok {
+		return code
+	}
+	return 1
+}
+
+// withCode returns a list with the code as the first key/value pair,
+// and any other code key/value pairs removed.
+func withCode(code string, list List) List {
+	result := make(List, 0, len(list)+2)
+	result = append(result, codeKey, code)
+	for i := 0; i < len(list); i += 2 {
+		if key, _ := list[i].(string); key == codeKey {
+			continue
+		}
+		result = append(result, list[i], list[i+1])
+	}
+	return result
+}
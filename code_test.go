@@ -0,0 +1,149 @@
+package kv
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		err        error
+		wantCode   string
+		wantText   string
+		wantStatus int
+		wantExit   int
+	}{
+		{
+			err:        nil,
+			wantCode:   "",
+			wantText:   "<nil>",
+			wantStatus: http.StatusOK,
+			wantExit:   0,
+		},
+		{
+			err:        errors.New("text"),
+			wantCode:   "",
+			wantText:   "text",
+			wantStatus: http.StatusInternalServerError,
+			wantExit:   1,
+		},
+		{
+			err:        NewError("not found").With("id", 1).WithCode(NotFound),
+			wantCode:   NotFound,
+			wantText:   "not found code=not_found id=1",
+			wantStatus: http.StatusNotFound,
+			wantExit:   66,
+		},
+		{
+			err:        Wrap(NewError("bad input").WithCode(Invalid), "second").With("a", 1),
+			wantCode:   Invalid,
+			wantText:   "second: bad input code=invalid a=1",
+			wantStatus: http.StatusBadRequest,
+			wantExit:   65,
+		},
+		{
+			err:        Wrap(NewError("first").WithCode(Conflict), "second").WithCode(Unavailable),
+			wantCode:   Unavailable,
+			wantText:   "second: first code=unavailable",
+			wantStatus: http.StatusServiceUnavailable,
+			wantExit:   69,
+		},
+		{
+			err:        NewError("text").WithCode("custom").With("code", 3),
+			wantCode:   "custom",
+			wantText:   "text code=custom",
+			wantStatus: http.StatusInternalServerError,
+			wantExit:   1,
+		},
+		{
+			err:        fmt.Errorf("foreign: %w", NewError("denied").WithCode(Unauthorized)),
+			wantCode:   Unauthorized,
+			wantText:   "foreign: denied code=unauthorized",
+			wantStatus: http.StatusUnauthorized,
+			wantExit:   77,
+		},
+		{
+			err:        Join(NewError("a").WithCode(NotFound)),
+			wantCode:   NotFound,
+			wantText:   "a code=not_found",
+			wantStatus: http.StatusNotFound,
+			wantExit:   66,
+		},
+		{
+			err:        Join(NewError("a").WithCode(NotFound), NewError("b").WithCode(NotFound)),
+			wantCode:   "",
+			wantText:   "2 errors: a; b errors.0.code=not_found errors.1.code=not_found",
+			wantStatus: http.StatusInternalServerError,
+			wantExit:   1,
+		},
+		{
+			err:        Join(NewError("a").WithCode(NotFound), NewError("b")).WithCode(Invalid),
+			wantCode:   Invalid,
+			wantText:   "2 errors: a; b code=invalid errors.0.code=not_found",
+			wantStatus: http.StatusBadRequest,
+			wantExit:   65,
+		},
+	}
+	for tn, tt := range tests {
+		if got, want := Code(tt.err), tt.wantCode; got != want {
+			t.Errorf("%d: code: got=%v, want=%v", tn, got, want)
+		}
+		if got, want := fmt.Sprint(tt.err), tt.wantText; got != want {
+			t.Errorf("%d: text:\n got=%v\nwant=%v", tn, got, want)
+		}
+		if got, want := HTTPStatus(tt.err), tt.wantStatus; got != want {
+			t.Errorf("%d: status: got=%v, want=%v", tn, got, want)
+		}
+		if got, want := ExitCode(tt.err), tt.wantExit; got != want {
+			t.Errorf("%d: exit: got=%v, want=%v", tn, got, want)
+		}
+	}
+}
+
+func TestPublicMessage(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{
+			err:  nil,
+			want: "",
+		},
+		{
+			err:  NewError("text"),
+			want: "",
+		},
+		{
+			err:  NewError("text").WithPublic("public text"),
+			want: "public text",
+		},
+		{
+			err:  Wrap(NewError("first").WithPublic("first public"), "second").With("a", 1),
+			want: "first public",
+		},
+		{
+			err:  Wrap(NewError("first").WithPublic("first public"), "second").WithPublic("second public"),
+			want: "second public",
+		},
+		{
+			err:  fmt.Errorf("foreign: %w", NewError("first").WithPublic("first public")),
+			want: "first public",
+		},
+		{
+			err:  Join(NewError("first").WithPublic("first public")),
+			want: "first public",
+		},
+	}
+	for tn, tt := range tests {
+		if got, want := PublicMessage(tt.err), tt.want; got != want {
+			t.Errorf("%d:\n got=%v\nwant=%v", tn, got, want)
+		}
+	}
+
+	err := NewError("cannot open file").With("file", "/etc/passwd").WithPublic("Not allowed.")
+	if got, want := err.Error(), `cannot open file file="/etc/passwd"`; got != want {
+		t.Errorf("\n got=%v\nwant=%v", got, want)
+	}
+}
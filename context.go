@@ -3,6 +3,7 @@ package kv
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/jjeffery/kv/internal/pool"
@@ -22,7 +23,9 @@ type Context interface {
 	context.Context
 
 	// With returns a new context based on the existing context,
-	// but with with the key/value pairs attached.
+	// but with with the key/value pairs attached. If keyvals includes
+	// a DupPolicy, it determines how duplicate keys are handled for
+	// the new context and any contexts derived from it.
 	With(keyvals ...interface{}) context.Context
 
 	// NewError returns a new error with the message text and
@@ -33,6 +36,22 @@ type Context interface {
 	// the optional message text, and the key/value pairs from
 	// the context attached.
 	Wrap(err error, text ...string) Error
+
+	// Without returns a new context based on the existing context,
+	// but without any key/value pairs with the keys. Key/value pairs
+	// attached later (for example by calling With on the new context)
+	// are not affected.
+	Without(keys ...string) context.Context
+
+	// Replace returns a new context based on the existing context,
+	// with the key/value pairs attached in place of any existing key/value
+	// pairs with the same keys. If keyvals includes a DupPolicy, it applies
+	// in the same way as for With.
+	Replace(keyvals ...interface{}) context.Context
+
+	// List returns the key/value pairs attached to the context,
+	// with any duplicate keys handled according to the DupPolicy.
+	List() List
 }
 
 // contextT implements the Context interface.
@@ -70,29 +89,146 @@ func (c *contextT) Value(key interface{}) interface{} {
 
 // With returns a context.Context with the keyvals attached.
 func (c *contextT) With(keyvals ...interface{}) context.Context {
-	return &contextT{ctx: newContext(c.ctx, keyvals)}
+	return &contextT{ctx: newContext(c.ctx, nil, keyvals)}
 }
 
-func newContext(ctx context.Context, keyvals []interface{}) context.Context {
-	if ctx == nil {
-		ctx = context.Background()
+// Without returns a context.Context without any key/value pairs
+// with the keys.
+func (c *contextT) Without(keys ...string) context.Context {
+	if len(keys) == 0 {
+		return c
+	}
+	removed := make([]string, len(keys))
+	copy(removed, keys)
+	return &contextT{ctx: linkContext(c.ctx, &ctxValue{removed: removed})}
+}
+
+// Replace returns a context.Context with the keyvals attached in
+// place of any existing key/value pairs with the same keys.
+func (c *contextT) Replace(keyvals ...interface{}) context.Context {
+	dup, keyvals := splitDupPolicy(keyvals)
+	list := List(flattenFixPolicy(keyvals, keyPolicy(ctxKeyPolicy(c.ctx))))
+	if len(list) == 0 && dup == 0 {
+		return c
 	}
-	if len(keyvals) == 0 {
+	return &contextT{ctx: linkContext(c.ctx, &ctxValue{
+		list:    list,
+		removed: list.Keys(),
+		dup:     dup,
+	})}
+}
+
+// List returns the key/value pairs attached to the context.
+func (c *contextT) List() List {
+	return c.list()
+}
+
+// ctxValue is the value stored in the context. Each call to With
+// creates a new ctxValue that is linked to the ctxValue of the parent
+// context, so that With does not need to copy the parent's key/value pairs.
+// The key/value pairs for the whole chain are only collected when they
+// are needed, and the result is kept for subsequent reads.
+type ctxValue struct {
+	list    List       // key/value pairs from this call to With
+	removed []string   // keys removed from the parent's key/value pairs
+	parent  *ctxValue  // value from the parent context, or nil
+	dup     DupPolicy  // policy for handling duplicate keys
+	keys    *KeyPolicy // policy for naming missing keys, nil for DefaultKeyPolicy
+
+	once  sync.Once
+	lists []List // key/value pairs from each call to With, most recent first
+}
+
+// newContext returns a context with keyvals attached. If keys is nil,
+// missing keys are named according to the policy of the parent context.
+func newContext(ctx context.Context, keys *KeyPolicy, keyvals []interface{}) context.Context {
+	dup, keyvals := splitDupPolicy(keyvals)
+	if len(keyvals) == 0 && dup == 0 && keys == nil {
+		if ctx == nil {
+			ctx = context.Background()
+		}
 		return ctx
 	}
 	keyvals = keyvals[:len(keyvals):len(keyvals)] // set capacity
-	keyvals = flattenFix(keyvals)
-	keyvals = append(keyvals, fromContext(ctx)...)
-	keyvals = keyvals[:len(keyvals):len(keyvals)] // set capacity
-	return context.WithValue(ctx, ctxKey, keyvals)
+	policy := keys
+	if policy == nil {
+		policy = ctxKeyPolicy(ctx)
+	}
+	return linkContext(ctx, &ctxValue{
+		list: List(flattenFixPolicy(keyvals, keyPolicy(policy))),
+		dup:  dup,
+		keys: keys,
+	})
 }
 
-func fromContext(ctx context.Context) []interface{} {
-	var keyvals []interface{}
-	if ctx != nil {
-		keyvals, _ = ctx.Value(ctxKey).([]interface{})
+// linkContext links v to the value in the parent context, and
+// returns a new context containing v.
+func linkContext(ctx context.Context, v *ctxValue) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	v.parent = ctxValueFrom(ctx)
+	if v.dup == 0 && v.parent != nil {
+		v.dup = v.parent.dup
+	}
+	if v.keys == nil && v.parent != nil {
+		v.keys = v.parent.keys
+	}
+	return context.WithValue(ctx, ctxKey, v)
+}
+
+func ctxValueFrom(ctx context.Context) *ctxValue {
+	if ctx == nil {
+		return nil
+	}
+	v, _ := ctx.Value(ctxKey).(*ctxValue)
+	return v
+}
+
+// getLists returns the key/value pairs from each call to With, most
+// recent first. They are collected from the chain of values the first
+// time that getLists is called.
+func (v *ctxValue) getLists() []List {
+	v.once.Do(func() {
+		var removed map[string]struct{}
+		for p := v; p != nil; p = p.parent {
+			if list := p.list.without(removed); len(list) > 0 {
+				v.lists = append(v.lists, list)
+			}
+			for _, key := range p.removed {
+				if removed == nil {
+					removed = make(map[string]struct{})
+				}
+				removed[key] = struct{}{}
+			}
+		}
+	})
+	return v.lists
+}
+
+// listsFromContext returns the key/value pairs attached by each call
+// to With, most recent first, and the policy for duplicate keys.
+func listsFromContext(ctx context.Context) ([]List, DupPolicy) {
+	if v := ctxValueFrom(ctx); v != nil {
+		return v.getLists(), v.dup
 	}
-	return keyvals
+	return nil, 0
+}
+
+// ctxKeyPolicy returns the policy for naming missing keys in the
+// context, or nil if the context uses DefaultKeyPolicy.
+func ctxKeyPolicy(ctx context.Context) *KeyPolicy {
+	if v := ctxValueFrom(ctx); v != nil {
+		return v.keys
+	}
+	return nil
+}
+
+// list returns the key/value pairs in the context, with duplicate
+// keys handled according to the context's policy.
+func (c *contextT) list() List {
+	lists, dup := listsFromContext(c.ctx)
+	return dedupPolicy(dup, lists...)
 }
 
 func (c *contextT) NewError(text string) Error {
@@ -104,8 +240,7 @@ func (c *contextT) Wrap(err error, text ...string) Error {
 }
 
 func (c *contextT) String() string {
-	list := List(fromContext(c.ctx))
-	return list.String()
+	return c.list().String()
 }
 
 // Format implements the fmt.Formatter interface. If
@@ -117,8 +252,7 @@ func (c *contextT) Format(f fmt.State, ch rune) {
 		return
 	}
 	buf := pool.AllocBuffer()
-	list := List(fromContext(c.ctx))
-	list.writeToBuffer(buf)
+	c.list().writeToBuffer(buf)
 	f.Write(buf.Bytes())
 	pool.ReleaseBuffer(buf)
 }
@@ -97,3 +97,139 @@ func TestContextFormat(t *testing.T) {
 		t.Errorf("\n got=%v\nwant=%v", got, want)
 	}
 }
+
+func TestContextChain(t *testing.T) {
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		ctx = From(ctx).With(fmt.Sprintf("k%d", i), i)
+	}
+	// reading the parent must not affect the child, and vice versa
+	parent := ctx
+	child := From(parent).With("k0", "child", "c", 1)
+	if got, want := fmt.Sprint(child), "k0=child c=1 k9=9 k8=8 k7=7 k6=6 k5=5 k4=4 k3=3 k2=2 k1=1 k0=0"; got != want {
+		t.Errorf("\n got=%v\nwant=%v", got, want)
+	}
+	if got, want := fmt.Sprint(parent), "k9=9 k8=8 k7=7 k6=6 k5=5 k4=4 k3=3 k2=2 k1=1 k0=0"; got != want {
+		t.Errorf("\n got=%v\nwant=%v", got, want)
+	}
+	lists, _ := listsFromContext(child)
+	var count int
+	for _, list := range lists {
+		count += len(list)
+	}
+	if got, want := count, 24; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+}
+
+func TestContextWithoutReplace(t *testing.T) {
+	base := From(context.Background()).With("user", "alice", "session", "s1", "token", "secret")
+	tests := []struct {
+		fn   func() context.Context
+		want string
+	}{
+		{
+			fn: func() context.Context {
+				return From(base).Without("token")
+			},
+			want: "user=alice session=s1",
+		},
+		{
+			fn: func() context.Context {
+				return From(base).Without()
+			},
+			want: "user=alice session=s1 token=secret",
+		},
+		{
+			fn: func() context.Context {
+				return From(base).With("user", "bob")
+			},
+			want: "user=bob user=alice session=s1 token=secret",
+		},
+		{
+			fn: func() context.Context {
+				return From(base).Replace("user", "bob")
+			},
+			want: "user=bob session=s1 token=secret",
+		},
+		{
+			fn: func() context.Context {
+				ctx := From(base).Without("user", "token")
+				return From(ctx).With("user", "bob", "a", 1)
+			},
+			want: "user=bob a=1 session=s1",
+		},
+		{
+			fn: func() context.Context {
+				ctx := From(base).Replace("user", "bob")
+				ctx = From(ctx).With("b", 2)
+				return From(ctx).Replace("user", "carol", LastWins)
+			},
+			want: "user=carol b=2 session=s1 token=secret",
+		},
+		{
+			fn: func() context.Context {
+				return From(nil).Without("user")
+			},
+			want: "",
+		},
+	}
+	for tn, tt := range tests {
+		ctx := tt.fn()
+		if got, want := From(ctx).List().String(), tt.want; got != want {
+			t.Errorf("%d:\n got=%v\nwant=%v", tn, got, want)
+		}
+	}
+	// the base context is not affected
+	if got, want := From(base).List().String(), "user=alice session=s1 token=secret"; got != want {
+		t.Errorf("\n got=%v\nwant=%v", got, want)
+	}
+}
+
+func BenchmarkContextWithDepth1(b *testing.B) {
+	benchmarkContextWith(b, 1)
+}
+
+func BenchmarkContextWithDepth10(b *testing.B) {
+	benchmarkContextWith(b, 10)
+}
+
+func BenchmarkContextWithDepth100(b *testing.B) {
+	benchmarkContextWith(b, 100)
+}
+
+func BenchmarkContextChainDepth10(b *testing.B) {
+	benchmarkContextChain(b, 10)
+}
+
+func BenchmarkContextChainDepth100(b *testing.B) {
+	benchmarkContextChain(b, 100)
+}
+
+// benchmarkContextWith measures the cost of With for a context
+// that already has depth calls to With in its chain.
+func benchmarkContextWith(b *testing.B, depth int) {
+	ctx := context.Background()
+	for i := 0; i < depth; i++ {
+		ctx = From(ctx).With("key", i)
+	}
+	c := From(ctx)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.With("key", i)
+	}
+}
+
+// benchmarkContextChain measures the cost of building a chain
+// of depth calls to With, and then reading the key/value pairs once.
+func benchmarkContextChain(b *testing.B, depth int) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ctx := context.Background()
+		for j := 0; j < depth; j++ {
+			ctx = From(ctx).With("key", j)
+		}
+		listsFromContext(ctx)
+	}
+}
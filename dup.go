@@ -0,0 +1,239 @@
+package kv
+
+import (
+	"fmt"
+
+	"github.com/jjeffery/kv/internal/logfmt"
+	"github.com/jjeffery/kv/internal/pool"
+)
+
+// DupPolicy determines how duplicate keys are handled when key/value
+// pairs from more than one source are rendered together. For example, a
+// message logged with a context might have a key that is also present in
+// the context, and an error might have a key that is also present in the
+// error that it wraps.
+//
+// The values attached most recently take precedence: values in a list
+// passed to Log override values in a context, values attached to a
+// context override values attached to its parent context, and values
+// attached to an error override values attached to the error that it
+// wraps. Within a single list, later values override earlier values.
+//
+// A DupPolicy can be passed as an argument to Log, Context.With and
+// Error.With to choose the policy for that call. Otherwise
+// DefaultDupPolicy applies.
+type DupPolicy int
+
+// Policies for handling duplicate keys.
+const (
+	// KeepDistinct keeps every distinct value for a key. If the same
+	// key/value pair appears more than once, it is only rendered once.
+	KeepDistinct DupPolicy = iota + 1
+
+	// LastWins keeps only the value for a key that was set most recently.
+	LastWins
+
+	// FirstWins keeps only the value for a key that was set first.
+	FirstWins
+
+	// KeepAll keeps every key/value pair, including identical duplicates.
+	KeepAll
+)
+
+// DefaultDupPolicy is the policy used when no policy has been
+// specified. It should only be modified during program initialization.
+var DefaultDupPolicy = KeepDistinct
+
+// String implements the fmt.Stringer interface.
+func (p DupPolicy) String() string {
+	switch p {
+	case KeepDistinct:
+		return "KeepDistinct"
+	case LastWins:
+		return "LastWins"
+	case FirstWins:
+		return "FirstWins"
+	case KeepAll:
+		return "KeepAll"
+	}
+	return fmt.Sprintf("DupPolicy(%d)", int(p))
+}
+
+// resolve returns the policy that applies for p. The zero
+// value means that DefaultDupPolicy applies.
+func (p DupPolicy) resolve() DupPolicy {
+	if p == 0 {
+		p = DefaultDupPolicy
+	}
+	if p < KeepDistinct || p > KeepAll {
+		p = KeepDistinct
+	}
+	return p
+}
+
+// splitDupPolicy removes any DupPolicy values from keyvals, and returns
+// the last one found, or zero if none are found. The keyvals slice
+// is not modified.
+func splitDupPolicy(keyvals []interface{}) (DupPolicy, []interface{}) {
+	var (
+		policy DupPolicy
+		others []interface{}
+	)
+	for i, kv := range keyvals {
+		if p, ok := kv.(DupPolicy); ok {
+			if others == nil {
+				others = make([]interface{}, i, len(keyvals))
+				copy(others, keyvals)
+			}
+			policy = p
+		} else if others != nil {
+			others = append(others, kv)
+		}
+	}
+	if others == nil {
+		return policy, keyvals
+	}
+	return policy, others
+}
+
+// dedup combines the lists according to DefaultDupPolicy.
+// See dedupPolicy.
+func dedup(lists ...List) List {
+	return dedupPolicy(0, lists...)
+}
+
+// dedupPolicy combines the lists, and handles duplicate keys according
+// to the policy. The lists are in order of precedence, with the list
+// containing the most recently set values first.
+//
+// Any lazy values are evaluated, so that they are only evaluated once
+// when the result is rendered.
+func dedupPolicy(policy DupPolicy, lists ...List) List {
+	var (
+		totalLen int
+	)
+	for _, list := range lists {
+		totalLen += len(list)
+	}
+	if totalLen == 0 {
+		return nil
+	}
+	contents := make([][]interface{}, len(lists))
+	for i, list := range lists {
+		contents[i] = flattenFix(list)
+	}
+
+	switch policy.resolve() {
+	case LastWins:
+		return dedupWins(contents, totalLen, true)
+	case FirstWins:
+		return dedupWins(contents, totalLen, false)
+	case KeepAll:
+		return dedupAll(contents, totalLen)
+	}
+	return dedupDistinct(contents, totalLen)
+}
+
+// dedupDistinct keeps every distinct value for each key.
+func dedupDistinct(contents [][]interface{}, totalLen int) List {
+	result := make(List, 0, totalLen)
+	m := make(map[string]map[string]struct{})
+	buf := pool.AllocBuffer()
+	defer pool.ReleaseBuffer(buf)
+
+	valueString := func(val interface{}) string {
+		buf.Reset()
+		logfmt.WriteValue(buf, val)
+		return buf.String()
+	}
+
+	for _, keyvals := range contents {
+		for i := 0; i < len(keyvals); i += 2 {
+			key := keyString(keyvals[i])
+			// Evaluate any lazy values, so that they are only evaluated once.
+			val := resolve(keyvals[i+1])
+			valstr := valueString(val)
+			valstrs, found := m[key]
+			if !found {
+				valstrs = make(map[string]struct{})
+				m[key] = valstrs
+			}
+			if _, ok := valstrs[valstr]; !ok {
+				result = append(result, key, val)
+				valstrs[valstr] = struct{}{}
+			}
+		}
+	}
+
+	return result
+}
+
+// dedupAll keeps every key/value pair.
+func dedupAll(contents [][]interface{}, totalLen int) List {
+	result := make(List, 0, totalLen)
+	for _, keyvals := range contents {
+		for i := 0; i < len(keyvals); i += 2 {
+			result = append(result, keyString(keyvals[i]), resolve(keyvals[i+1]))
+		}
+	}
+	return result
+}
+
+// dedupWins keeps one value for each key: the most recently set value
+// if last is true, otherwise the first value set. Each key appears in
+// the result at the position where it first appears in contents.
+func dedupWins(contents [][]interface{}, totalLen int, last bool) List {
+	winners := make(map[string]interface{})
+	choose := func(keyvals []interface{}, i int) {
+		key := keyString(keyvals[i])
+		if _, ok := winners[key]; !ok {
+			winners[key] = keyvals[i+1]
+		}
+	}
+	if last {
+		// the first list has the most recent values,
+		// and the last value in each list is the most recent
+		for _, keyvals := range contents {
+			for i := len(keyvals) - 2; i >= 0; i -= 2 {
+				choose(keyvals, i)
+			}
+		}
+	} else {
+		for j := len(contents) - 1; j >= 0; j-- {
+			keyvals := contents[j]
+			for i := 0; i < len(keyvals); i += 2 {
+				choose(keyvals, i)
+			}
+		}
+	}
+
+	result := make(List, 0, 2*len(winners))
+	for _, keyvals := range contents {
+		for i := 0; i < len(keyvals); i += 2 {
+			key := keyString(keyvals[i])
+			if val, ok := winners[key]; ok {
+				result = append(result, key, resolve(val))
+				delete(winners, key)
+			}
+		}
+	}
+	return result
+}
+
+// resolve evaluates any lazy value. The result is rendered as-is, so it
+// is not expanded into key/value pairs if the list is flattened again.
+func resolve(value interface{}) interface{} {
+	return opaque(logfmt.Resolve(value))
+}
+
+// keyString returns the key as a string.
+func keyString(key interface{}) string {
+	s, ok := key.(string)
+	if !ok {
+		// shouldn't happen, unless a different type is
+		// returned for missing keys, which might happen
+		// if the flatten/fix function is modified in future
+		s = fmt.Sprint(key)
+	}
+	return s
+}
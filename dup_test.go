@@ -0,0 +1,111 @@
+package kv
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDedupPolicy(t *testing.T) {
+	lists := []List{
+		{"a", 1, "b", 2, "a", 3},
+		{"a", 1, "c", 4, "b", 5},
+	}
+	tests := []struct {
+		policy DupPolicy
+		want   string
+	}{
+		{policy: KeepDistinct, want: "a=1 b=2 a=3 c=4 b=5"},
+		{policy: LastWins, want: "a=3 b=2 c=4"},
+		{policy: FirstWins, want: "a=1 b=5 c=4"},
+		{policy: KeepAll, want: "a=1 b=2 a=3 a=1 c=4 b=5"},
+		{policy: 0, want: "a=1 b=2 a=3 c=4 b=5"},
+		{policy: 99, want: "a=1 b=2 a=3 c=4 b=5"},
+	}
+	for tn, tt := range tests {
+		if got, want := dedupPolicy(tt.policy, lists...).String(), tt.want; got != want {
+			t.Errorf("%d: %v:\n got=%v\nwant=%v", tn, tt.policy, got, want)
+		}
+	}
+}
+
+func TestDedupPolicyDefault(t *testing.T) {
+	defer func(p DupPolicy) { DefaultDupPolicy = p }(DefaultDupPolicy)
+	DefaultDupPolicy = LastWins
+
+	ctx := From(context.Background()).With("user", "alice", "method", "get")
+	ctx = From(ctx).With("user", "bob")
+	if got, want := fmt.Sprint(ctx), "user=bob method=get"; got != want {
+		t.Errorf("\n got=%v\nwant=%v", got, want)
+	}
+
+	err := From(ctx).NewError("permission denied").With("user", "carol")
+	if got, want := err.Error(), "permission denied user=carol method=get"; got != want {
+		t.Errorf("\n got=%v\nwant=%v", got, want)
+	}
+
+	err = Wrap(err, "cannot open file").With("user", "dave")
+	if got, want := err.Error(), "cannot open file: permission denied user=dave method=get"; got != want {
+		t.Errorf("\n got=%v\nwant=%v", got, want)
+	}
+}
+
+func TestDedupPolicyPerCall(t *testing.T) {
+	var output []string
+	defer func(logOutput func(int, string) error) {
+		LogOutput = logOutput
+	}(LogOutput)
+	LogOutput = func(calldepth int, s string) error {
+		output = append(output, strings.TrimSpace(s))
+		return nil
+	}
+
+	ctx := From(context.Background()).With("user", "alice")
+	ctx2 := From(ctx).With("user", "bob")
+	ctx3 := From(ctx).With(FirstWins, "user", "bob")
+
+	Log("message", ctx2)
+	Log("message", ctx2, LastWins)
+	Log("message", ctx2, FirstWins)
+	Log("message", ctx3)
+	Log("message", ctx3, With("user", "carol"), LastWins)
+
+	want := []string{
+		"message user=bob user=alice",
+		"message user=bob",
+		"message user=alice",
+		"message user=alice",
+		"message user=carol",
+	}
+	if got, want := strings.Join(output, "\n"), strings.Join(want, "\n"); got != want {
+		t.Errorf("\n got=%v\nwant=%v", got, want)
+	}
+
+	if got, want := fmt.Sprint(ctx3), "user=alice"; got != want {
+		t.Errorf("\n got=%v\nwant=%v", got, want)
+	}
+
+	err := NewError("message").With("a", 1).With("a", 2, LastWins)
+	if got, want := err.Error(), "message a=2"; got != want {
+		t.Errorf("\n got=%v\nwant=%v", got, want)
+	}
+	err = err.With("a", 3)
+	if got, want := err.Error(), "message a=3"; got != want {
+		t.Errorf("\n got=%v\nwant=%v", got, want)
+	}
+}
+
+func TestSplitDupPolicy(t *testing.T) {
+	keyvals := []interface{}{"a", 1, LastWins, "b", 2, FirstWins}
+	policy, others := splitDupPolicy(keyvals)
+	if got, want := policy, FirstWins; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+	if got, want := fmt.Sprint(others), "[a 1 b 2]"; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+	if got, want := len(keyvals), 6; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+}
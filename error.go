@@ -2,6 +2,9 @@ package kv
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"strings"
 
 	"github.com/jjeffery/kv/internal/pool"
@@ -13,15 +16,40 @@ type Error interface {
 	error
 
 	// With returns a new error based on this error
-	// with the key/value pairs attached.
+	// with the key/value pairs attached. If keyvals includes
+	// a DupPolicy, it determines how duplicate keys are handled
+	// when the error message is rendered.
 	With(keyvals ...interface{}) Error
+
+	// WithStack returns a new error based on this error with
+	// the stack trace of the caller attached, regardless of the
+	// value of CaptureStacks.
+	WithStack() Error
+
+	// WithCode returns a new error based on this error with
+	// the code attached. The code classifies the error, and is
+	// usually one of the predefined codes such as NotFound or Invalid.
+	WithCode(code string) Error
+
+	// WithPublic returns a new error based on this error with
+	// a public message attached. The public message is safe to show to
+	// end users, and does not appear in the error message.
+	WithPublic(msg string) Error
 }
 
 type errorT struct {
-	text    string
-	list    List
-	ctxlist List
-	err     error
+	text     string
+	list     List
+	ctxlists []List
+	dup      DupPolicy
+	keys     *KeyPolicy // policy for missing keys, nil for DefaultKeyPolicy
+	err      error
+	stack    []uintptr
+	code     string
+	errs     []error
+	public   string
+	inline   bool // text includes the message of the wrapped errors
+	parsed   bool // created by ParseError
 }
 
 var _ Error = &errorT{}
@@ -42,10 +70,220 @@ func newError(ctx context.Context, err error, text ...string) *errorT {
 		text: strings.Join(text, " "),
 		err:  err,
 	}
-	e.ctxlist = append(e.ctxlist, fromContext(ctx)...)
+	e.ctxlists, e.dup = listsFromContext(ctx)
+	e.keys = ctxKeyPolicy(ctx)
+	if CaptureStacks {
+		// skip runtime.Callers, callers, newError and
+		// the exported function that called newError
+		e.stack = callers(4)
+	}
 	return e
 }
 
+// Fields returns the key/value pairs associated with err, including
+// the key/value pairs associated with any errors that it wraps. The
+// values have the same types as when they were attached to the error.
+//
+// If err (or an error that it wraps) was not created by this package, its
+// key/value pairs are obtained by parsing its error message. If it implements
+// a Keyvals() []interface{} method, the values returned by that method are
+// used instead. Values attached to an error created by this package keep
+// their types, even when it is wrapped by an error that was not.
+func Fields(err error) List {
+	_, list := errorParts(err)
+	return list
+}
+
+// fieldLists returns the key/value pairs associated with err as separate
+// lists, most recent first, and the policy for duplicate keys. Fields
+// returns the same key/value pairs combined into one list.
+func fieldLists(err error) ([]List, DupPolicy) {
+	e := asErrorT(err)
+	if e == nil {
+		if err == nil {
+			return nil, 0
+		}
+		_, list := foreignErrorParts(err)
+		return []List{list}, 0
+	}
+	var lists []List
+	if code := Code(e); code != "" {
+		lists = append(lists, List{codeKey, code})
+	}
+	lists = append(lists, e.list)
+	switch {
+	case e.inline:
+		for _, err := range append([]error{e.err}, e.errs...) {
+			prevLists, _ := fieldLists(err)
+			lists = append(lists, prevLists...)
+		}
+	case len(e.errs) == 1:
+		prevLists, _ := fieldLists(e.errs[0])
+		lists = append(lists, prevLists...)
+	case len(e.errs) > 0:
+		_, prevList := joinedParts(e.errs)
+		lists = append(lists, prevList)
+	default:
+		prevLists, _ := fieldLists(e.err)
+		lists = append(lists, prevLists...)
+	}
+	lists = append(lists, e.ctxlists...)
+	return lists, e.dup
+}
+
+// Message returns the message text of err without any key/value pairs.
+// The message text includes the text of any wrapped errors, each separated
+// by a colon and a space (": ").
+func Message(err error) string {
+	text, _ := errorParts(err)
+	return text
+}
+
+// errorParts returns the message text and the key/value pairs for err.
+func errorParts(err error) (text string, list List) {
+	if err == nil {
+		return "", nil
+	}
+	if e := asErrorT(err); e != nil {
+		return e.parts()
+	}
+	return foreignErrorParts(err)
+}
+
+// asErrorT returns the *errorT associated with err, or nil if err
+// was not created by this package.
+func asErrorT(err error) *errorT {
+	switch e := err.(type) {
+	case *errorT:
+		return e
+	case *causerT:
+		return e.errorT
+	}
+	return nil
+}
+
+// foreignErrorParts returns the message text and the key/value pairs
+// for an error that was not created by this package. Its error
+// message is parsed for key/value pairs. If it wraps an error created
+// by this package, the values from that error keep their original types.
+func foreignErrorParts(err error) (string, List) {
+	textb, list := Parse([]byte(err.Error()))
+	text := string(textb)
+	fromMsg := false
+	if len(text) == 0 && len(list) > 0 {
+		// The message consists only of key/value pairs.
+		// Search for a key indicating the message.
+		text, list = removeMsg(list)
+		fromMsg = text != ""
+	}
+	if kvs, ok := err.(keyvalser); ok {
+		// Prefer the key/value pairs with their original types.
+		list = List(flattenFix(kvs.Keyvals())).clone(0)
+		if fromMsg {
+			_, list = removeMsg(list)
+		}
+	} else if e := unwrapErrorT(err); e != nil {
+		_, typed := e.parts()
+		list = typedValues(list, typed)
+	}
+	return text, list
+}
+
+// unwrapErrorT returns the first error created by this package in the
+// chain of errors wrapped by err, or nil if there is none.
+func unwrapErrorT(err error) *errorT {
+	for err = errors.Unwrap(err); err != nil; err = errors.Unwrap(err) {
+		if e := asErrorT(err); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// typedValues returns list, which was parsed from an error message, with
+// each value replaced by the value for the same key in typed, which has
+// its original type. Values in typed without a matching key in list
+// are appended.
+func typedValues(list, typed List) List {
+	values := make(map[string][]interface{})
+	for i := 0; i < len(typed); i += 2 {
+		key := keyString(typed[i])
+		values[key] = append(values[key], typed[i+1])
+	}
+	result := make(List, 0, len(list)+len(typed))
+	for i := 0; i < len(list); i += 2 {
+		key, value := keyString(list[i]), list[i+1]
+		if vs := values[key]; len(vs) > 0 {
+			value, values[key] = vs[0], vs[1:]
+		}
+		result = append(result, key, value)
+	}
+	for i := 0; i < len(typed); i += 2 {
+		key := keyString(typed[i])
+		if vs := values[key]; len(vs) > 0 {
+			result = append(result, key, vs[0])
+			values[key] = vs[1:]
+		}
+	}
+	return result
+}
+
+// removeMsg searches the list for the first key indicating a message
+// with a string value. If found, it returns the message and a list with the
+// message key/value pair removed. The list is modified in place.
+func removeMsg(list List) (string, List) {
+	keyMsg := msgKey()
+	for i := 0; i < len(list); i += 2 {
+		if key, _ := list[i].(string); key == keyMsg {
+			if value, ok := list[i+1].(string); ok {
+				copy(list[i:], list[i+2:])
+				return value, list[:len(list)-2]
+			}
+		}
+	}
+	return "", list
+}
+
+// parts returns the message text and key/value pairs for the error,
+// including any errors that it wraps.
+func (e *errorT) parts() (text string, list List) {
+	var (
+		prevText  string
+		prevLists []List
+	)
+	text = strings.TrimSpace(e.text)
+	switch {
+	case e.inline:
+		// the text already includes the message of each wrapped error
+		for _, err := range append([]error{e.err}, e.errs...) {
+			if err != nil {
+				_, prevList := errorParts(err)
+				prevLists = append(prevLists, prevList)
+			}
+		}
+	case len(e.errs) > 0:
+		var prevList List
+		prevText, prevList = joinedParts(e.errs)
+		prevLists = append(prevLists, prevList)
+	case e.err != nil:
+		var prevList List
+		prevText, prevList = errorParts(e.err)
+		prevLists = append(prevLists, prevList)
+	}
+	if len(text) > 0 && len(prevText) > 0 {
+		text = text + ": " + prevText
+	} else {
+		text += prevText
+	}
+	lists := append([]List{e.list}, prevLists...)
+	lists = append(lists, e.ctxlists...)
+	list = dedupPolicy(e.dup, lists...)
+	if code := Code(e); code != "" {
+		list = withCode(code, list)
+	}
+	return text, list
+}
+
 // Error implements the error interface.
 //
 // The string returned prints the error text of this error
@@ -53,49 +291,12 @@ func newError(ctx context.Context, err error, text ...string) *errorT {
 // After the error message (or messages) comes the key/value pairs.
 // The resulting string can be parsed with the Parse function.
 func (e *errorT) Error() string {
-	var (
-		text     = strings.TrimSpace(e.text)
-		prevText []byte
-		prevList List
-	)
-	if e.err != nil {
-		input := []byte(e.err.Error())
-		prevText, prevList = Parse(input)
-		if len(prevText) == 0 && len(prevList) > 0 {
-			// The previous message consists only of key/value
-			// pairs. Search for a key indicating the message.
-			i := 0
-			newLen := len(prevList)
-			for ; i < len(prevList); i += 2 {
-				key, _ := prevList[i].(string)
-				if key == "msg" {
-					if value, ok := prevList[i+1].(string); ok {
-						prevText = []byte(value)
-						newLen -= 2
-						break
-					}
-				}
-			}
-			for ; i < len(prevList)-2; i += 2 {
-				prevList[i] = prevList[i+2]
-				prevList[i+1] = prevList[i+3]
-			}
-			prevList = prevList[:newLen]
-		}
-	}
+	text, list := e.parts()
 
 	buf := pool.AllocBuffer()
 	defer pool.ReleaseBuffer(buf)
 
-	if len(text) > 0 {
-		buf.WriteString(text)
-		if len(prevText) > 0 {
-			buf.WriteString(": ")
-		}
-	}
-	buf.Write(prevText)
-
-	list := dedup(e.list, prevList, e.ctxlist)
+	buf.WriteString(text)
 	if len(list) > 0 {
 		if buf.Len() > 0 {
 			buf.WriteRune(' ')
@@ -107,12 +308,72 @@ func (e *errorT) Error() string {
 }
 
 func (e *errorT) With(keyvals ...interface{}) Error {
-	return causer(&errorT{
-		text:    e.text,
-		list:    e.list.With(keyvals...),
-		ctxlist: e.ctxlist,
-		err:     e.err,
-	})
+	dup, keyvals := splitDupPolicy(keyvals)
+	if dup == 0 {
+		dup = e.dup
+	}
+	e2 := e.clone()
+	e2.list = e.list.withPolicy(keyPolicy(e.keys), keyvals)
+	e2.dup = dup
+	return causer(e2)
+}
+
+func (e *errorT) WithStack() Error {
+	e2 := e.clone()
+	// skip runtime.Callers, callers and WithStack
+	e2.stack = callers(3)
+	return causer(e2)
+}
+
+func (e *errorT) WithCode(code string) Error {
+	e2 := e.clone()
+	e2.code = code
+	return causer(e2)
+}
+
+func (e *errorT) WithPublic(msg string) Error {
+	e2 := e.clone()
+	e2.public = msg
+	return causer(e2)
+}
+
+// StackTrace returns the stack trace captured when the error was
+// created, or nil if no stack trace was captured. It is compatible
+// with the StackTrace method of errors created by github.com/pkg/errors.
+//
+// The stack trace only applies to this error: errors that this error
+// wraps may have their own stack traces.
+func (e *errorT) StackTrace() StackTrace {
+	return stackTrace(e.stack)
+}
+
+// Format implements the fmt.Formatter interface. The %s and %v verbs
+// print the error message. The %+v verb prints the error message followed
+// by the stack traces of this error and any errors that it wraps.
+func (e *errorT) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			io.WriteString(s, e.Error())
+			for err := error(e); err != nil; err = errors.Unwrap(err) {
+				if et := asErrorT(err); et != nil && len(et.stack) > 0 {
+					et.StackTrace().Format(s, verb)
+				}
+			}
+			return
+		}
+		fallthrough
+	case 's':
+		io.WriteString(s, e.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", e.Error())
+	}
+}
+
+// clone returns a shallow copy of the error.
+func (e *errorT) clone() *errorT {
+	e2 := *e
+	return &e2
 }
 
 // Unwrap implements the Wrapper interface.
@@ -3,7 +3,10 @@ package kv
 import (
 	"context"
 	"errors"
+	"fmt"
+	"reflect"
 	"testing"
+	"time"
 )
 
 // keyvalserError is an error that implements the keyvalser interface.
@@ -133,6 +136,69 @@ func TestError(t *testing.T) {
 	}
 }
 
+func TestFieldsMessage(t *testing.T) {
+	tm := time.Date(2099, 12, 31, 12, 34, 56, 0, time.UTC)
+	tests := []struct {
+		err      error
+		wantMsg  string
+		wantList List
+	}{
+		{
+			err:      nil,
+			wantMsg:  "",
+			wantList: nil,
+		},
+		{
+			err:      NewError("message text").With("a", 1, "t", tm),
+			wantMsg:  "message text",
+			wantList: List{"a", 1, "t", tm},
+		},
+		{
+			err:      Wrap(NewError("first").With("a", 1, "b", 2), "second").With("c", int64(3)),
+			wantMsg:  "second: first",
+			wantList: List{"c", int64(3), "a", 1, "b", 2},
+		},
+		{
+			err:      Wrap(errors.New("first a=1"), "second").With("b", 2),
+			wantMsg:  "second: first",
+			wantList: List{"b", 2, "a", "1"},
+		},
+		{
+			err:      Wrap(keyvalserError{"msg", "first", "a", 1}, "second"),
+			wantMsg:  "second: first",
+			wantList: List{"a", 1},
+		},
+		{
+			err:      Wrap(fmt.Errorf("foreign: %w b=x", NewError("first").With("a", 1, "t", tm))),
+			wantMsg:  "foreign: first",
+			wantList: List{"a", 1, "t", tm, "b", "x"},
+		},
+		{
+			err:      Wrap(fmt.Errorf("foreign: %w", Wrap(NewError("first").With("a", 1), "second").With("a", 2))),
+			wantMsg:  "foreign: second: first",
+			wantList: List{"a", 2, "a", 1},
+		},
+		{
+			err:      Wrap(fmt.Errorf("hidden: %v", NewError("first").With("a", 1))),
+			wantMsg:  "hidden: first",
+			wantList: List{"a", "1"},
+		},
+		{
+			err:      From(From(context.Background()).With("c", 3)).NewError("text").With("a", 1),
+			wantMsg:  "text",
+			wantList: List{"a", 1, "c", 3},
+		},
+	}
+	for tn, tt := range tests {
+		if got, want := Message(tt.err), tt.wantMsg; got != want {
+			t.Errorf("%d: msg:\n got=%v\nwant=%v", tn, got, want)
+		}
+		if got, want := Fields(tt.err), tt.wantList; !reflect.DeepEqual(got, want) {
+			t.Errorf("%d: fields:\n got=%#v\nwant=%#v", tn, got, want)
+		}
+	}
+}
+
 /*
 func TestUnwrap(t *testing.T) {
 	err1 := errors.New("error 1")
@@ -0,0 +1,143 @@
+package kv
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Errorf returns an error with message text formatted according to a
+// format specifier, in the same way as fmt.Errorf.
+//
+// If the format specifier includes a %w verb with an error operand, the
+// returned error wraps that error. The message text of the wrapped error
+// appears in place of the %w verb, and its key/value pairs are rendered
+// after the message text, so that
+//
+//	err := kv.NewError("permission denied").With("user", "alice")
+//	err = kv.Errorf("cannot open %s: %w", "/etc/passwd", err)
+//
+// renders as
+//
+//	cannot open /etc/passwd: permission denied user=alice
+//
+// Any arguments after those required by the format specifier are
+// attached to the error as key/value pairs. These can include lists,
+// and a context, whose key/value pairs are also attached:
+//
+//	err := kv.Errorf("cannot open %s: %w", file, err, "attempt", 3, ctx)
+func Errorf(format string, args ...interface{}) Error {
+	format, n, wrapped := scanFormat(format)
+	if n > len(args) {
+		n = len(args)
+	}
+	fmtArgs := make([]interface{}, n)
+	copy(fmtArgs, args)
+
+	var causes []error
+	for _, i := range wrapped {
+		if i >= n {
+			continue
+		}
+		if err, ok := fmtArgs[i].(error); ok {
+			causes = append(causes, err)
+			fmtArgs[i] = Message(err)
+		}
+	}
+
+	var (
+		ctx     context.Context
+		dup     DupPolicy
+		keyvals []interface{}
+	)
+	for _, arg := range args[n:] {
+		switch v := arg.(type) {
+		case context.Context:
+			ctx = v
+		case DupPolicy:
+			dup = v
+		default:
+			keyvals = append(keyvals, arg)
+		}
+	}
+
+	e := newError(ctx, nil, fmt.Sprintf(format, fmtArgs...))
+	if len(keyvals) > 0 {
+		e.list = List(flattenFixPolicy(keyvals, keyPolicy(e.keys)))
+	}
+	if dup != 0 {
+		e.dup = dup
+	}
+	if len(causes) > 0 {
+		e.inline = true
+		if len(causes) == 1 {
+			e.err = causes[0]
+		} else {
+			e.errs = causes
+		}
+	}
+	return causer(e)
+}
+
+// scanFormat returns the format specifier with any %w verbs replaced
+// with %v, the number of arguments required by the format specifier,
+// and the index of the argument for each %w verb.
+func scanFormat(format string) (string, int, []int) {
+	var (
+		buf     strings.Builder
+		argNum  int
+		maxArgs int
+		wrapped []int
+	)
+	use := func() {
+		argNum++
+		if argNum > maxArgs {
+			maxArgs = argNum
+		}
+	}
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		buf.WriteByte(c)
+		if c != '%' {
+			continue
+		}
+	verb:
+		for i++; i < len(format); i++ {
+			c = format[i]
+			switch {
+			case c == '[':
+				// explicit argument index
+				end := strings.IndexByte(format[i:], ']')
+				if end < 0 {
+					buf.WriteString(format[i:])
+					i = len(format)
+					break verb
+				}
+				if index, err := strconv.Atoi(format[i+1 : i+end]); err == nil && index > 0 {
+					argNum = index - 1
+				}
+				buf.WriteString(format[i : i+end+1])
+				i += end
+			case c == '*':
+				use()
+				buf.WriteByte(c)
+			case strings.IndexByte("+-# 0.123456789", c) >= 0:
+				buf.WriteByte(c)
+			case c == '%':
+				buf.WriteByte(c)
+				break verb
+			case c == 'w':
+				wrapped = append(wrapped, argNum)
+				use()
+				buf.WriteByte('v')
+				break verb
+			default:
+				use()
+				buf.WriteByte(c)
+				break verb
+			}
+		}
+	}
+	return buf.String(), maxArgs, wrapped
+}
@@ -0,0 +1,91 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestErrorf(t *testing.T) {
+	ctx := From(context.Background()).With("request", 42)
+	cause := NewError("permission denied").With("user", "alice")
+
+	tests := []struct {
+		err  Error
+		want string
+	}{
+		{
+			err:  Errorf("plain text"),
+			want: "plain text",
+		},
+		{
+			err:  Errorf("value %d of %s", 3, "five"),
+			want: "value 3 of five",
+		},
+		{
+			err:  Errorf("cannot open %s: %w", "/etc/passwd", cause),
+			want: "cannot open /etc/passwd: permission denied user=alice",
+		},
+		{
+			err:  Errorf("cannot open %s: %w", "/etc/passwd", cause, "attempt", 3),
+			want: "cannot open /etc/passwd: permission denied attempt=3 user=alice",
+		},
+		{
+			err:  Errorf("cannot read: %w", io.EOF, List{"file", "data"}, ctx),
+			want: "cannot read: EOF file=data request=42",
+		},
+		{
+			err:  Errorf("%w (while closing: %w)", cause, errors.New("closed")),
+			want: "permission denied (while closing: closed) user=alice",
+		},
+		{
+			err:  Errorf("%[2]s %[1]s", "world", "hello", "a", 1),
+			want: "hello world a=1",
+		},
+		{
+			err:  Errorf("100%% done %*d", 4, 7, "a", 1),
+			want: "100% done    7 a=1",
+		},
+		{
+			err:  Errorf("missing %s"),
+			want: "missing %!s(MISSING)",
+		},
+		{
+			err:  Errorf("extra", "id", 5),
+			want: "extra id=5",
+		},
+	}
+	for tn, tt := range tests {
+		if got, want := tt.err.Error(), tt.want; got != want {
+			t.Errorf("%d:\n got=%v\nwant=%v", tn, got, want)
+		}
+	}
+}
+
+func TestErrorfUnwrap(t *testing.T) {
+	cause := NewError("permission denied").With("user", "alice").WithCode(Unauthorized)
+	err := Errorf("cannot open %s: %w", "/etc/passwd", cause).With("file", "/etc/passwd")
+
+	if got, want := errors.Unwrap(err), error(cause); got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+	if !errors.Is(err, cause) {
+		t.Errorf("want errors.Is to match cause")
+	}
+	if got, want := Code(err), Unauthorized; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+	if got, want := Message(err), "cannot open /etc/passwd: permission denied"; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+	if got, want := Fields(err), (List{"code", Unauthorized, "file", "/etc/passwd", "user", "alice"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("\n got=%v\nwant=%v", got, want)
+	}
+
+	err = Errorf("%w and %w", io.EOF, io.ErrUnexpectedEOF)
+	if !errors.Is(err, io.EOF) || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("want errors.Is to match both causes")
+	}
+}
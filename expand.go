@@ -0,0 +1,198 @@
+package kv
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxStructDepth is the maximum depth of nested structs and maps that
+// will be expanded into key/value pairs. It prevents infinite recursion for
+// values that refer to themselves.
+const maxStructDepth = 8
+
+// structField contains information about a struct field that is
+// expanded into a key/value pair.
+type structField struct {
+	name      string // key name, without any prefix
+	index     int    // field index
+	omitEmpty bool   // omit if the field has its zero value
+	inline    bool   // expand a struct field without a prefix
+	embedded  bool   // unexported embedded struct
+}
+
+// structInfo contains the fields of a struct that are expanded into
+// key/value pairs.
+type structInfo struct {
+	fields  []structField
+	skipped int // exported fields ignored because of their tag
+}
+
+// expandable reports whether any of the struct's fields are expanded.
+// A struct whose fields are all unexported has nothing to expand, so it
+// is rendered as a single value instead. A struct whose fields are all
+// ignored because of their tag is still expanded, so that the fields
+// are not rendered.
+func (info *structInfo) expandable() bool {
+	return len(info.fields) > 0 || info.skipped > 0
+}
+
+// structCache is a map of reflect.Type to *structInfo.
+var structCache sync.Map
+
+// expandable reports whether the value should be expanded into key/value
+// pairs. Structs and non-nil pointers to structs are expanded, unless they
+// know how to render themselves as text, are evaluated lazily, or have no
+// exported fields. Non-nil maps with string keys are also expanded.
+func expandable(value interface{}) bool {
+	switch value.(type) {
+	case nil, string, []byte, bool, byte, int8, int16, uint16, int32, uint32, int64, uint64, int, uint, uintptr, float32, float64, complex64, complex128:
+		return false
+	case keyvalser, Valuer, encoding.TextMarshaler, error, fmt.Stringer:
+		return false
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Ptr:
+		return !v.IsNil() && v.Elem().Kind() == reflect.Struct && getStructInfo(v.Elem().Type()).expandable()
+	case reflect.Struct:
+		return getStructInfo(v.Type()).expandable()
+	case reflect.Map:
+		return !v.IsNil() && v.Type().Key().Kind() == reflect.String
+	}
+	return false
+}
+
+// appendExpanded appends key/value pairs to output for the contents of v,
+// which is a value that has been reported as expandable. Each key is prefixed
+// with prefix.
+func appendExpanded(output []interface{}, prefix string, v reflect.Value, depth int) []interface{} {
+	if v.Kind() == reflect.Map {
+		return appendMap(output, prefix, v, depth)
+	}
+	return appendStruct(output, prefix, v, depth)
+}
+
+// appendMap appends a key/value pair to output for each entry in the map v,
+// sorted by key. Each key is prefixed with prefix.
+func appendMap(output []interface{}, prefix string, v reflect.Value, depth int) []interface{} {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	for _, k := range keys {
+		key := prefix + k.String()
+		value := v.MapIndex(k).Interface()
+		if depth < maxStructDepth && expandable(value) {
+			output = appendExpanded(output, key+keySeparator, reflect.ValueOf(value), depth+1)
+			continue
+		}
+		output = append(output, key, value)
+	}
+	return output
+}
+
+// appendStruct appends a key/value pair to output for each field in the struct v,
+// which is a struct or a pointer to a struct. Each key is prefixed with prefix.
+func appendStruct(output []interface{}, prefix string, v reflect.Value, depth int) []interface{} {
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	info := getStructInfo(v.Type())
+	for _, field := range info.fields {
+		fv := v.Field(field.index)
+		if field.omitEmpty && fv.IsZero() {
+			continue
+		}
+		if field.inline && fv.Kind() == reflect.Ptr && fv.IsNil() {
+			// nothing to expand
+			continue
+		}
+		if field.embedded {
+			// The embedded struct cannot be converted to an interface
+			// because it is unexported, but its exported fields can be.
+			if depth < maxStructDepth {
+				output = appendStruct(output, prefix, fv, depth+1)
+			}
+			continue
+		}
+		key := prefix + field.name
+		value := fv.Interface()
+		if depth < maxStructDepth && expandable(value) {
+			fieldPrefix := prefix
+			if !field.inline {
+				fieldPrefix = key + keySeparator
+			}
+			output = appendExpanded(output, fieldPrefix, reflect.ValueOf(value), depth+1)
+			continue
+		}
+		output = append(output, key, value)
+	}
+	return output
+}
+
+// getStructInfo returns information about the fields of the struct type,
+// which is only calculated once for each type.
+func getStructInfo(t reflect.Type) *structInfo {
+	if info, ok := structCache.Load(t); ok {
+		return info.(*structInfo)
+	}
+	info, _ := structCache.LoadOrStore(t, newStructInfo(t))
+	return info.(*structInfo)
+}
+
+// newStructInfo builds information about the fields of a struct
+// type from the "kv" tags of its fields.
+//
+// The field name is used as the key unless the tag specifies a name.
+// Fields with a tag of "-" are ignored, as are unexported fields. The
+// tag options "omitempty" and "inline" are recognized. Embedded structs
+// are inline unless the tag specifies a name. The exported fields of
+// unexported embedded structs are always inline.
+func newStructInfo(t reflect.Type) *structInfo {
+	info := &structInfo{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("kv")
+		if tag == "-" {
+			if f.PkgPath == "" {
+				info.skipped++
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			// Unexported fields are ignored, except for embedded
+			// structs, which can have exported fields.
+			if f.Anonymous && f.Type.Kind() == reflect.Struct {
+				info.fields = append(info.fields, structField{
+					index:    i,
+					inline:   true,
+					embedded: true,
+				})
+			}
+			continue
+		}
+		opts := strings.Split(tag, ",")
+		field := structField{
+			name:   opts[0],
+			index:  i,
+			inline: f.Anonymous && opts[0] == "",
+		}
+		if field.name == "" {
+			field.name = f.Name
+		}
+		for _, opt := range opts[1:] {
+			switch strings.TrimSpace(opt) {
+			case "omitempty":
+				field.omitEmpty = true
+			case "inline":
+				field.inline = true
+			}
+		}
+		info.fields = append(info.fields, field)
+	}
+	return info
+}
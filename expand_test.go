@@ -0,0 +1,241 @@
+package kv
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testAddress struct {
+	Street string `kv:"street"`
+	City   string `kv:"city,omitempty"`
+}
+
+type testAudit struct {
+	CreatedBy string `kv:"created_by"`
+}
+
+type testUser struct {
+	ID       int64  `kv:"id"`
+	Name     string `kv:"name"`
+	Password string `kv:"-"`
+	Email    string `kv:",omitempty"`
+	Address  testAddress
+	Postal   *testAddress `kv:"postal"`
+	Meta     testAudit    `kv:",inline"`
+	Created  time.Time    `kv:"created"`
+	internal int
+	testEmbedded
+}
+
+type testEmbedded struct {
+	Version int `kv:"version"`
+}
+
+type testHidden struct {
+	a, b int
+}
+
+type testSecret struct {
+	Password string `kv:"-"`
+	internal int
+}
+
+type testNode struct {
+	Name string    `kv:"name"`
+	Next *testNode `kv:"next"`
+}
+
+func TestFlattenStruct(t *testing.T) {
+	created := time.Date(2099, 12, 31, 12, 34, 56, 0, time.UTC)
+	user := testUser{
+		ID:       42,
+		Name:     "alice",
+		Password: "secret",
+		Address:  testAddress{Street: "1 Main St"},
+		Meta:     testAudit{CreatedBy: "bob"},
+		Created:  created,
+		internal: 1,
+		testEmbedded: testEmbedded{
+			Version: 3,
+		},
+	}
+	userKeyvals := []interface{}{
+		"id", int64(42),
+		"name", "alice",
+		"Address.street", "1 Main St",
+		"postal", (*testAddress)(nil),
+		"created_by", "bob",
+		"created", created,
+		"version", 3,
+	}
+	prefixed := func(prefix string, keyvals []interface{}) []interface{} {
+		var result []interface{}
+		for i := 0; i < len(keyvals); i += 2 {
+			result = append(result, prefix+keyvals[i].(string), keyvals[i+1])
+		}
+		return result
+	}
+
+	node := &testNode{Name: "a"}
+	node.Next = node
+
+	tests := []struct {
+		v    []interface{}
+		want []interface{}
+	}{
+		{
+			v:    []interface{}{user},
+			want: userKeyvals,
+		},
+		{
+			v:    []interface{}{&user},
+			want: userKeyvals,
+		},
+		{
+			v:    []interface{}{"user", user},
+			want: prefixed("user.", userKeyvals),
+		},
+		{
+			v:    []interface{}{"msg", "text", &user, "a", 1},
+			want: append(append([]interface{}{"msg", "text"}, userKeyvals...), "a", 1),
+		},
+		{
+			v:    []interface{}{"addr", testAddress{City: "Sydney"}},
+			want: []interface{}{"addr.street", "", "addr.city", "Sydney"},
+		},
+		{
+			v:    []interface{}{"addr", (*testAddress)(nil)},
+			want: []interface{}{"addr", (*testAddress)(nil)},
+		},
+		{
+			v:    []interface{}{"t", created},
+			want: []interface{}{"t", created},
+		},
+		{
+			v:    []interface{}{"h", testHidden{1, 2}, "e", struct{}{}, "after", 1},
+			want: []interface{}{"h", testHidden{1, 2}, "e", struct{}{}, "after", 1},
+		},
+		{
+			v:    []interface{}{"h", &testHidden{1, 2}},
+			want: []interface{}{"h", &testHidden{1, 2}},
+		},
+		{
+			v:    []interface{}{"s", testSecret{Password: "secret"}, "after", 1},
+			want: []interface{}{"after", 1},
+		},
+		{
+			v: []interface{}{node},
+			want: []interface{}{
+				"name", "a",
+				"next.name", "a",
+				"next.next.name", "a",
+				"next.next.next.name", "a",
+				"next.next.next.next.name", "a",
+				"next.next.next.next.next.name", "a",
+				"next.next.next.next.next.next.name", "a",
+				"next.next.next.next.next.next.next.name", "a",
+				"next.next.next.next.next.next.next.next.name", "a",
+				"next.next.next.next.next.next.next.next.next", node,
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		got := flattenFix(tt.v)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d:\nwant %v\n got %v", i, tt.want, got)
+		}
+	}
+
+	if got, want := With("user", testAddress{Street: "1 Main St", City: "Sydney"}).String(),
+		`user.street="1 Main St" user.city=Sydney`; got != want {
+		t.Errorf("\n got=%v\nwant=%v", got, want)
+	}
+	if got, want := With("h", testHidden{1, 2}, "e", struct{}{}, "after", 1).String(),
+		`h="{1 2}" e="{}" after=1`; got != want {
+		t.Errorf("\n got=%v\nwant=%v", got, want)
+	}
+}
+
+type testLabel string
+
+type testConfig struct {
+	Name   string                 `kv:"name"`
+	Labels map[testLabel]string   `kv:"labels"`
+	Extra  map[string]interface{} `kv:",inline"`
+	Any    interface{}            `kv:"any,omitempty"`
+}
+
+func TestFlattenMap(t *testing.T) {
+	tests := []struct {
+		v    []interface{}
+		want []interface{}
+	}{
+		{
+			v:    []interface{}{map[string]string{"b": "2", "a": "1", "c": "3"}},
+			want: []interface{}{"a", "1", "b", "2", "c", "3"},
+		},
+		{
+			v:    []interface{}{"msg", "text", "cfg", map[string]int{"z": 26, "y": 25}},
+			want: []interface{}{"msg", "text", "cfg.y", 25, "cfg.z", 26},
+		},
+		{
+			v: []interface{}{"cfg", map[string]interface{}{
+				"db": map[string]interface{}{
+					"port": 5432,
+					"host": "localhost",
+				},
+				"debug": true,
+			}},
+			want: []interface{}{"cfg.db.host", "localhost", "cfg.db.port", 5432, "cfg.debug", true},
+		},
+		{
+			v:    []interface{}{"m", map[string]int{}, "a", 1},
+			want: []interface{}{"a", 1},
+		},
+		{
+			v:    []interface{}{"m", map[string]int(nil)},
+			want: []interface{}{"m", map[string]int(nil)},
+		},
+		{
+			v:    []interface{}{"m", map[int]string{1: "one"}},
+			want: []interface{}{"m", map[int]string{1: "one"}},
+		},
+		{
+			v: []interface{}{"cfg", testConfig{
+				Name:   "test",
+				Labels: map[testLabel]string{"env": "prod", "app": "kv"},
+				Extra:  map[string]interface{}{"x": 1},
+				Any:    testAddress{Street: "1 Main St"},
+			}},
+			want: []interface{}{
+				"cfg.name", "test",
+				"cfg.labels.app", "kv",
+				"cfg.labels.env", "prod",
+				"cfg.x", 1,
+				"cfg.any.street", "1 Main St",
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		got := flattenFix(tt.v)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d:\nwant %v\n got %v", i, tt.want, got)
+		}
+	}
+
+	headers := map[string]string{"Content-Type": "text/plain", "Accept": "*/*"}
+	if got, want := With("headers", headers).String(),
+		`headers.Accept="*/*" headers.Content-Type="text/plain"`; got != want {
+		t.Errorf("\n got=%v\nwant=%v", got, want)
+	}
+}
+
+func BenchmarkFlattenStruct(b *testing.B) {
+	user := testUser{ID: 42, Name: "alice"}
+	for i := 0; i < b.N; i++ {
+		flattenFix([]interface{}{"user", &user})
+	}
+}
@@ -0,0 +1,23 @@
+package kv
+
+import "github.com/jjeffery/kv/internal/fingerprint"
+
+// Fingerprint returns a string that identifies errors that are similar
+// to err. It is useful for grouping errors together in order to count them.
+//
+// The fingerprint is a hash of the message text of err (see Message) and
+// the keys of its key/value pairs (see Fields). The values do not affect
+// the fingerprint, so the errors
+//
+//	permission denied user=alice
+//	permission denied user=bob
+//
+// have the same fingerprint. Fingerprint returns a blank string if
+// err is nil.
+func Fingerprint(err error) string {
+	if err == nil {
+		return ""
+	}
+	text, list := errorParts(err)
+	return fingerprint.Compute(text, list.Keys())
+}
@@ -0,0 +1,48 @@
+package kv
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		err1 error
+		err2 error
+		same bool
+	}{
+		{
+			err1: NewError("permission denied").With("user", "alice"),
+			err2: NewError("permission denied").With("user", "bob"),
+			same: true,
+		},
+		{
+			err1: Wrap(NewError("permission denied").With("user", "alice"), "cannot open").With("file", "a"),
+			err2: Wrap(NewError("permission denied").With("user", "bob"), "cannot open").With("file", "b"),
+			same: true,
+		},
+		{
+			err1: NewError("permission denied").With("user", "alice"),
+			err2: errors.New("permission denied user=bob"),
+			same: true,
+		},
+		{
+			err1: NewError("permission denied").With("user", "alice"),
+			err2: NewError("permission denied").With("group", "admin"),
+			same: false,
+		},
+		{
+			err1: Wrap(NewError("permission denied"), "cannot open"),
+			err2: Wrap(NewError("permission denied"), "cannot close"),
+			same: false,
+		},
+	}
+	for tn, tt := range tests {
+		if got, want := Fingerprint(tt.err1) == Fingerprint(tt.err2), tt.same; got != want {
+			t.Errorf("%d: got=%v, want=%v", tn, got, want)
+		}
+	}
+	if got, want := Fingerprint(nil), ""; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+}
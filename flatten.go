@@ -1,7 +1,7 @@
 package kv
 
 import (
-	"regexp"
+	"reflect"
 	"strconv"
 )
 
@@ -16,12 +16,22 @@ type keyvalser interface {
 // slice of key/value pairs. The returned slice is guaranteed
 // to have an even number of items, and every item at an even-numbered
 // index is guaranteed to be a string.
+//
+// Missing keys are named according to DefaultKeyPolicy.
 func flattenFix(keyvals []interface{}) []interface{} {
-	// opinionated constants for names of keys
-	const (
-		keyMsg           = "msg"
-		keyError         = "error"
-		keyMissingPrefix = "_p"
+	return flattenFixPolicy(keyvals, DefaultKeyPolicy)
+}
+
+// flattenFixPolicy is the same as flattenFix, except that missing
+// keys are named according to policy.
+func flattenFixPolicy(keyvals []interface{}, policy *KeyPolicy) []interface{} {
+	if policy == nil {
+		policy = &KeyPolicy{}
+	}
+	var (
+		keyMsg           = policy.MsgKey
+		keyError         = policy.ErrorKey
+		keyMissingPrefix = policy.missingPrefix()
 	)
 
 	// Indicates whether the keyvals slice needs to be flattened.
@@ -52,7 +62,7 @@ func flattenFix(keyvals []interface{}) []interface{} {
 			requiresFlattening = true
 			estimatedLen += 16
 		case string:
-			if v == keyMsg {
+			if v == keyMsg && keyMsg != "" {
 				// Remember that we already have a "msg" key, which
 				// will be used for inferring missing key names later.
 				haveMsg = true
@@ -64,6 +74,9 @@ func flattenFix(keyvals []interface{}) []interface{} {
 				// key name in the list.
 				estimatedLen++
 				requiresFlattening = true
+			} else if expandable(v) {
+				// Structs and maps are expanded into key/value pairs.
+				requiresFlattening = true
 			}
 		}
 	}
@@ -88,7 +101,7 @@ func flattenFix(keyvals []interface{}) []interface{} {
 	output := make([]interface{}, 0, estimatedLen)
 
 	// Perform the actual flattening and fixing.
-	output = flatten(output, keyvals, missingKey)
+	output = flatten(output, keyvals, missingKey, policy)
 
 	// If there were any missing keys inserted, iterate through the
 	// list and name them. Doing this last allows the names to be
@@ -102,16 +115,18 @@ func flattenFix(keyvals []interface{}) []interface{} {
 				var keyName string
 				switch output[i+1].(type) {
 				case string:
-					if !haveMsg {
+					if !haveMsg && keyMsg != "" {
 						// If there is no 'msg' key, the first string
 						// value gets 'msg' as its key.
 						haveMsg = true
 						keyName = keyMsg
 					}
 				case error:
-					if haveMsg {
+					if haveMsg || keyMsg == "" {
 						// If there is already a 'msg' key, then an
-						// error gets 'error' as the key.
+						// error gets 'error' as the key. (If the policy
+						// has no error key, keyError is blank and the
+						// error is numbered like other missing keys).
 						keyName = keyError
 					} else {
 						// If there is no 'msg' key, the first error
@@ -135,6 +150,10 @@ func flattenFix(keyvals []interface{}) []interface{} {
 	return output
 }
 
+// keySeparator separates the prefix from the rest of a key name
+// when key/value pairs are nested inside another key.
+const keySeparator = "."
+
 // isEven returns true if i is even.
 func isEven(i int) bool {
 	return (i & 0x01) == 0
@@ -150,17 +169,48 @@ func isOdd(i int) bool {
 // to right.
 type missingKeyT string
 
+// The opaqueValue type holds a value that is rendered as-is, and is never
+// expanded into key/value pairs, even when the list that contains it is
+// flattened again. It is used for the value of a Pair.
+type opaqueValue struct {
+	value interface{}
+}
+
+// Value implements the Valuer interface.
+func (v opaqueValue) Value() interface{} {
+	return v.value
+}
+
+// opaque returns value wrapped in an opaqueValue if it would otherwise
+// be expanded into key/value pairs.
+func opaque(value interface{}) interface{} {
+	if expandable(value) {
+		return opaqueValue{value: value}
+	}
+	return value
+}
+
+// unwrapOpaque returns the value held by an opaqueValue, or value
+// unchanged if it is not an opaqueValue.
+func unwrapOpaque(value interface{}) interface{} {
+	if v, ok := value.(opaqueValue); ok {
+		return v.value
+	}
+	return value
+}
+
 func flatten(
 	output []interface{},
 	input []interface{},
 	missingKeyName func() interface{},
+	policy *KeyPolicy,
 ) []interface{} {
 	for len(input) > 0 {
 		// Process any leading scalars. A scalar is any single value,
 		// ie not a keyvalsAppender, keyvalser, keyvalPairer or keyvalMapper.
 		// This makes it easier to figure out any missing key names.
 		if i := countScalars(input); i > 0 {
-			output = flattenScalars(output, input[:i], missingKeyName)
+			output = flattenScalars(output, input[:i], missingKeyName, policy)
 			input = input[i:]
 			continue
 		}
@@ -168,11 +218,15 @@ func flatten(
 		// At this point the first item in the input is a keyvalsAppender,
 		// keyvalser, keyvalPairer, or keyvalMapper.
 		switch v := input[0].(type) {
+		case Pair:
+			// A pair is always a single key/value pair, and its
+			// value is never expanded.
+			output = append(output, v.Key, opaque(v.Value))
 		case keyvalser:
 			// The Keyvals method does not guarantee to return a valid
 			// key/value list, so flatten and fix it as if this slice
 			// had been passed to the flattenFix function in the first place.
-			output = flatten(output, v.Keyvals(), missingKeyName)
+			output = flatten(output, v.Keyvals(), missingKeyName, policy)
 		default:
 			//panic("cannot happen")
 		}
@@ -204,6 +258,7 @@ func flattenScalars(
 	output []interface{},
 	input []interface{},
 	missingKeyName func() interface{},
+	policy *KeyPolicy,
 ) []interface{} {
 	for len(input) > 0 {
 		var needsFixing bool
@@ -223,7 +278,7 @@ func flattenScalars(
 		}
 
 		if !needsFixing {
-			output = append(output, input...)
+			output = appendPairs(output, input)
 			input = nil
 			continue
 		}
@@ -244,9 +299,9 @@ func flattenScalars(
 		for i := 0; i < len(input); i++ {
 			switch v := input[i].(type) {
 			case string:
-				if _, ok := knownKeys[v]; ok {
+				if policy.isKnownKey(v) {
 					classifications[i] = stringKey
-				} else if possibleKeyRE.MatchString(v) {
+				} else if policy.isPossibleKey(v) {
 					classifications[i] = stringPossibleKey
 				} else {
 					classifications[i] = stringValue
@@ -261,8 +316,7 @@ func flattenScalars(
 		if len(input) == 1 {
 			// Only one parameter, give it a key name. If it is a string it might
 			// be the 'msg' parameter.
-			output = append(output, missingKeyName())
-			output = append(output, input[0])
+			output = appendPairs(output, []interface{}{missingKeyName(), input[0]})
 			input = nil
 			continue
 		}
@@ -320,10 +374,6 @@ func flattenScalars(
 	return output
 }
 
-// possibleKeyRE is a regexp for deciding if a string value is likely
-// to be a key rather than a value.
-var possibleKeyRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.]*$`)
-
 func insertKeyAt(input []interface{}, index int, keyName interface{}) []interface{} {
 	newInput := make([]interface{}, 0, len(input)+1)
 	if index > 0 {
@@ -334,9 +384,24 @@ func insertKeyAt(input []interface{}, index int, keyName interface{}) []interfac
 	return newInput
 }
 
-// this could be public and configurable
-var knownKeys = map[string]struct{}{
-	"msg":   {},
-	"level": {},
-	"id":    {},
+// appendPairs appends the key/value pairs in input to output. Any values
+// that are structs are expanded into key/value pairs for each of their fields,
+// and any maps with string keys are expanded into key/value pairs for each
+// of their entries, sorted by key. If a struct or map value has a key, then
+// the key is a prefix for the expanded keys. If the key is missing, the
+// struct or map is expanded in place.
+func appendPairs(output []interface{}, input []interface{}) []interface{} {
+	for i := 0; i < len(input); i += 2 {
+		key, value := input[i], input[i+1]
+		if expandable(value) {
+			var prefix string
+			if s, ok := key.(string); ok {
+				prefix = s + keySeparator
+			}
+			output = appendExpanded(output, prefix, reflect.ValueOf(value), 0)
+			continue
+		}
+		output = append(output, key, value)
+	}
+	return output
 }
@@ -1,8 +1,10 @@
 package kv
 
 import (
+	"context"
 	"io"
 	"reflect"
+	"regexp"
 	"testing"
 )
 
@@ -85,6 +87,112 @@ func TestFlatten(t *testing.T) {
 	}
 }
 
+func TestFlattenPolicy(t *testing.T) {
+	custom := &KeyPolicy{
+		MsgKey:        "message",
+		ErrorKey:      "err",
+		MissingPrefix: "arg",
+		KnownKeys:     []string{"message", "severity", "id"},
+		PossibleKey:   regexp.MustCompile(`^[a-z]+$`),
+	}
+	tests := []struct {
+		policy *KeyPolicy
+		v      []interface{}
+		want   []interface{}
+	}{
+		{
+			policy: custom,
+			v:      []interface{}{"not found", "id", "A12345678"},
+			want:   []interface{}{"message", "not found", "id", "A12345678"},
+		},
+		{
+			policy: custom,
+			v:      []interface{}{"message", "the message", io.EOF, 1},
+			want:   []interface{}{"message", "the message", "err", io.EOF, "arg1", 1},
+		},
+		{
+			policy: custom,
+			v:      []interface{}{"message", "severity", "id"},
+			want:   []interface{}{"arg1", "message", "severity", "id"},
+		},
+		{
+			policy: &KeyPolicy{},
+			v:      []interface{}{"not found"},
+			want:   []interface{}{"_p1", "not found"},
+		},
+		{
+			policy: &KeyPolicy{MsgKey: "msg"},
+			v:      []interface{}{"msg", "message", io.EOF},
+			want:   []interface{}{"msg", "message", "_p1", io.EOF},
+		},
+		{
+			policy: nil,
+			v:      []interface{}{io.EOF},
+			want:   []interface{}{"_p1", io.EOF},
+		},
+	}
+
+	for i, tt := range tests {
+		got := flattenFixPolicy(tt.v, tt.policy)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d: want %v, got %v", i, tt.want, got)
+		}
+	}
+
+	list := custom.With("the message").With("a", 1)
+	if got, want := list.String(), "message=\"the message\" a=1"; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+}
+
+func TestPolicyList(t *testing.T) {
+	custom := &KeyPolicy{
+		MsgKey:   "message",
+		ErrorKey: "err",
+	}
+	list := custom.With("a", 1).With("text")
+	if got, want := list.String(), "a=1 message=text"; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+	if got, want := list.With("more", io.ErrUnexpectedEOF).String(), "a=1 message=text more=\"unexpected EOF\""; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+	if got, want := list.With("message", "x", io.EOF).String(), "a=1 message=text message=x err=EOF"; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+	if got, want := list.Group("g").With("more").String(), "g.a=1 g.message=text message=more"; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+
+	err := custom.With("a", 1).NewError("error text").With("text")
+	if got, want := err.Error(), "error text a=1 message=text"; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+	err = custom.With("a", 1).Wrap(io.EOF).With("text")
+	if got, want := err.Error(), "EOF a=1 message=text"; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+
+	var ctx context.Context = custom.With("a", 1).From(context.Background())
+	ctx = From(ctx).With("text")
+	ctx = From(ctx).Replace("more")
+	if got, want := From(ctx).List().String(), "message=more a=1"; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+	err = From(ctx).NewError("error text").With("other")
+	if got, want := err.Error(), "error text message=other message=more a=1"; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+
+	// other lists and contexts are not affected
+	if got, want := With("a", 1).With("text").String(), "a=1 msg=text"; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+	if got, want := From(context.Background()).With("text").(Context).List().String(), "msg=text"; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+}
+
 type testKeyvalser struct{}
 
 func (tkv testKeyvalser) Keyvals() []interface{} {
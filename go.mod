@@ -1,3 +1,5 @@
 module github.com/jjeffery/kv
 
+go 1.18
+
 require golang.org/x/sys v0.0.0-20181221143128-b4a75ba826a6
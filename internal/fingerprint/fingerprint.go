@@ -0,0 +1,33 @@
+// Package fingerprint computes fingerprints for grouping
+// similar messages together.
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+)
+
+// size is the number of bytes of the hash used in a fingerprint.
+const size = 8
+
+// Compute returns a fingerprint for a message with the given text and
+// keys. The fingerprint does not depend on the order of the keys, or on
+// whether any of the keys appear more than once.
+func Compute(text string, keys []string) string {
+	sorted := make([]string, len(keys))
+	copy(sorted, keys)
+	sort.Strings(sorted)
+
+	h := sha256.New()
+	h.Write([]byte(text))
+	for i, key := range sorted {
+		if i > 0 && key == sorted[i-1] {
+			continue
+		}
+		// separator cannot appear in the text of a key
+		h.Write([]byte{0})
+		h.Write([]byte(key))
+	}
+	return hex.EncodeToString(h.Sum(nil)[:size])
+}
@@ -0,0 +1,59 @@
+package fingerprint
+
+import "testing"
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		text1 string
+		keys1 []string
+		text2 string
+		keys2 []string
+		same  bool
+	}{
+		{
+			text1: "permission denied",
+			keys1: []string{"user"},
+			text2: "permission denied",
+			keys2: []string{"user"},
+			same:  true,
+		},
+		{
+			text1: "permission denied",
+			keys1: []string{"user", "file"},
+			text2: "permission denied",
+			keys2: []string{"file", "user", "file"},
+			same:  true,
+		},
+		{
+			text1: "permission denied",
+			keys1: []string{"user"},
+			text2: "permission denied",
+			keys2: []string{"user", "file"},
+			same:  false,
+		},
+		{
+			text1: "permission denied",
+			keys1: nil,
+			text2: "access denied",
+			keys2: nil,
+			same:  false,
+		},
+		{
+			text1: "a",
+			keys1: []string{"b"},
+			text2: "ab",
+			keys2: nil,
+			same:  false,
+		},
+	}
+	for tn, tt := range tests {
+		fp1 := Compute(tt.text1, tt.keys1)
+		fp2 := Compute(tt.text2, tt.keys2)
+		if got, want := len(fp1), 16; got != want {
+			t.Errorf("%d: len: got=%v, want=%v", tn, got, want)
+		}
+		if got, want := fp1 == fp2, tt.same; got != want {
+			t.Errorf("%d: got=%v, want=%v", tn, got, want)
+		}
+	}
+}
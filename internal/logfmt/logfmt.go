@@ -10,6 +10,9 @@ import (
 	"strings"
 	"unicode"
 	"unicode/utf8"
+
+	"github.com/jjeffery/kv/internal/pool"
+	"github.com/jjeffery/kv/internal/redact"
 )
 
 // constant byte values
@@ -29,6 +32,46 @@ var (
 	}
 )
 
+// valuer is implemented by values that are evaluated when they are
+// rendered. The kv package exports an identical interface.
+type valuer interface {
+	Value() interface{}
+}
+
+// panicValue is the result of evaluating a valuer that panics.
+type panicValue struct{}
+
+func (panicValue) String() string {
+	return string(bytesPanic)
+}
+
+// maxResolveDepth limits the number of times a valuer that
+// returns another valuer is evaluated.
+const maxResolveDepth = 8
+
+// Resolve returns the result of evaluating value if it is a valuer,
+// otherwise it returns value unchanged. If evaluating the value panics,
+// the result renders as "PANIC".
+func Resolve(value interface{}) interface{} {
+	for depth := 0; depth < maxResolveDepth; depth++ {
+		v, ok := value.(valuer)
+		if !ok {
+			return value
+		}
+		value = evaluate(v)
+	}
+	return panicValue{}
+}
+
+func evaluate(v valuer) (value interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			value = panicValue{}
+		}
+	}()
+	return v.Value()
+}
+
 // Writer is an interface implemented by both bytes.Buffer and strings.Builder
 type Writer interface {
 	Write(p []byte) (n int, err error)
@@ -36,13 +79,58 @@ type Writer interface {
 	WriteRune(r rune) (n int, err error)
 }
 
-// WriteKeyValue writes a key/value pair to the writer.
+// WriteKeyValue writes a key/value pair to the writer. If the
+// key or the value matches any of the redaction rules, the value
+// is replaced with the redaction text.
 func WriteKeyValue(buf Writer, key, value interface{}) {
+	if redact.Enabled() {
+		writeRedactedKeyValue(buf, key, value)
+		return
+	}
 	writeKey(buf, key)
 	buf.WriteRune('=')
 	WriteValue(buf, value)
 }
 
+// Redact reports whether the value associated with key should be
+// redacted according to the redaction rules, and if so returns the
+// replacement text. The key and value are rendered in logfmt format
+// before the rules are checked, so the result is the same regardless of
+// the output format.
+func Redact(key, value interface{}) (replacement string, ok bool) {
+	if !redact.Enabled() {
+		return "", false
+	}
+	kbuf := pool.AllocBuffer()
+	vbuf := pool.AllocBuffer()
+	defer pool.ReleaseBuffer(kbuf)
+	defer pool.ReleaseBuffer(vbuf)
+	return redactKeyValue(kbuf, vbuf, key, value)
+}
+
+// redactKeyValue renders the key and value into kbuf and vbuf, and
+// checks the rendered key and value against the redaction rules.
+func redactKeyValue(kbuf, vbuf *bytes.Buffer, key, value interface{}) (string, bool) {
+	writeKey(kbuf, key)
+	WriteValue(vbuf, value)
+	return redact.Redact(kbuf.String(), vbuf.Bytes())
+}
+
+func writeRedactedKeyValue(buf Writer, key, value interface{}) {
+	kbuf := pool.AllocBuffer()
+	vbuf := pool.AllocBuffer()
+	defer pool.ReleaseBuffer(kbuf)
+	defer pool.ReleaseBuffer(vbuf)
+	replacement, ok := redactKeyValue(kbuf, vbuf, key, value)
+	buf.Write(kbuf.Bytes())
+	buf.WriteRune('=')
+	if ok {
+		writeStringValue(buf, replacement)
+		return
+	}
+	buf.Write(vbuf.Bytes())
+}
+
 func writeKey(buf Writer, value interface{}) {
 	switch v := value.(type) {
 	case nil:
@@ -159,6 +247,9 @@ func WriteValue(buf Writer, value interface{}) {
 	case bool, byte, int8, int16, uint16, int32, uint32, int64, uint64, int, uint, uintptr, float32, float64, complex64, complex128:
 		fmt.Fprint(buf, v)
 		return
+	case valuer:
+		WriteValue(buf, Resolve(v))
+		return
 	case encoding.TextMarshaler:
 		writeTextMarshalerValue(buf, v)
 		return
@@ -122,6 +122,16 @@ func TestWriteKeyValue(t *testing.T) {
 			value: "value:",
 			want:  `key="value:"`,
 		},
+		{
+			key:   "key",
+			value: testValuer(func() interface{} { return "the value" }),
+			want:  `key="the value"`,
+		},
+		{
+			key:   "key",
+			value: testValuer(func() interface{} { panic("value") }),
+			want:  `key=PANIC`,
+		},
 	}
 	for i, tt := range tests {
 		doTest := func(key interface{}, value interface{}, want string) {
@@ -155,6 +165,12 @@ func (t testStringer) String() string {
 	return string(t)
 }
 
+type testValuer func() interface{}
+
+func (t testValuer) Value() interface{} {
+	return t()
+}
+
 type testTextMarshaler string
 
 func (t testTextMarshaler) MarshalText() ([]byte, error) {
@@ -0,0 +1,186 @@
+// Package redact provides a registry of rules for redacting
+// sensitive values when key/value pairs are rendered.
+package redact
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Text is the text that replaces a redacted value.
+const Text = "[REDACTED]"
+
+// hashSize is the number of bytes of the hash in replacement text.
+const hashSize = 8
+
+// textPrefix is the common prefix for all replacement text,
+// with or without a hash.
+var textPrefix = []byte(Text[:len(Text)-1])
+
+// rules is an immutable set of redaction rules.
+type rules struct {
+	keys     []string         // exact names or glob patterns, lower case
+	keyREs   []*regexp.Regexp // regexps for matching keys
+	valueREs []*regexp.Regexp // regexps for matching values
+	hashKey  []byte           // key for hashing values, nil for no hash
+}
+
+var (
+	mutex   sync.Mutex   // serializes changes to the rules
+	current atomic.Value // contains *rules
+)
+
+func load() *rules {
+	r, _ := current.Load().(*rules)
+	return r
+}
+
+// update applies fn to a copy of the current rules.
+func update(fn func(r *rules)) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	var r rules
+	if old := load(); old != nil {
+		r.keys = append(r.keys, old.keys...)
+		r.keyREs = append(r.keyREs, old.keyREs...)
+		r.valueREs = append(r.valueREs, old.valueREs...)
+		r.hashKey = old.hashKey
+	}
+	fn(&r)
+	current.Store(&r)
+}
+
+// AddKeys adds patterns for matching keys.
+func AddKeys(patterns ...string) {
+	update(func(r *rules) {
+		for _, p := range patterns {
+			r.keys = append(r.keys, strings.ToLower(p))
+		}
+	})
+}
+
+// AddKeyRegexp adds a regular expression for matching keys.
+func AddKeyRegexp(re *regexp.Regexp) {
+	if re != nil {
+		update(func(r *rules) {
+			r.keyREs = append(r.keyREs, re)
+		})
+	}
+}
+
+// AddValueRegexp adds a regular expression for matching values.
+func AddValueRegexp(re *regexp.Regexp) {
+	if re != nil {
+		update(func(r *rules) {
+			r.valueREs = append(r.valueREs, re)
+		})
+	}
+}
+
+// SetHashKey sets the key used for hashing redacted values.
+// If key is empty, redacted values are not hashed.
+func SetHashKey(key []byte) {
+	update(func(r *rules) {
+		if len(key) == 0 {
+			r.hashKey = nil
+		} else {
+			r.hashKey = append([]byte(nil), key...)
+		}
+	})
+}
+
+// Reset removes all rules.
+func Reset() {
+	mutex.Lock()
+	current.Store(&rules{})
+	mutex.Unlock()
+}
+
+// Enabled reports whether any rules have been registered.
+func Enabled() bool {
+	r := load()
+	return r != nil && (len(r.keys) > 0 || len(r.keyREs) > 0 || len(r.valueREs) > 0)
+}
+
+// Redact reports whether the value associated with key should be
+// redacted, and if so returns the replacement text. The value is
+// the value as rendered in logfmt format. Values that have already
+// been redacted are not redacted again.
+func Redact(key string, value []byte) (replacement string, ok bool) {
+	r := load()
+	if r == nil || isRedacted(value) {
+		return "", false
+	}
+	if !r.matchKey(key) && !r.matchValue(value) {
+		return "", false
+	}
+	if r.hashKey == nil {
+		return Text, true
+	}
+	mac := hmac.New(sha256.New, r.hashKey)
+	mac.Write(value)
+	sum := mac.Sum(nil)
+	return string(textPrefix) + ":" + hex.EncodeToString(sum[:hashSize]) + "]", true
+}
+
+func (r *rules) matchKey(key string) bool {
+	if len(r.keys) > 0 {
+		lower := strings.ToLower(key)
+		for _, pattern := range r.keys {
+			if pattern == lower {
+				return true
+			}
+			if matched, _ := path.Match(pattern, lower); matched {
+				return true
+			}
+		}
+	}
+	for _, re := range r.keyREs {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *rules) matchValue(value []byte) bool {
+	for _, re := range r.valueREs {
+		if re.Match(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRedacted reports whether the value is exactly one of the forms of
+// replacement text, possibly with quotes around it. Any other value, even
+// one that starts with the replacement text, is not considered redacted.
+func isRedacted(value []byte) bool {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		value = value[1 : len(value)-1]
+	}
+	if string(value) == Text {
+		return true
+	}
+	// [REDACTED:<hash>]
+	if !bytes.HasPrefix(value, textPrefix) {
+		return false
+	}
+	hash := value[len(textPrefix):]
+	if len(hash) != 1+2*hashSize+1 || hash[0] != ':' || hash[len(hash)-1] != ']' {
+		return false
+	}
+	for _, c := range hash[1 : len(hash)-1] {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
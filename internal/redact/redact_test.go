@@ -0,0 +1,79 @@
+package redact
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	defer Reset()
+	Reset()
+	if Enabled() {
+		t.Fatal("want not enabled")
+	}
+	AddKeys("password", "*token*", "[")
+	AddKeyRegexp(regexp.MustCompile(`^secret_`))
+	AddValueRegexp(regexp.MustCompile(`\d{4}-\d{4}`))
+	if !Enabled() {
+		t.Fatal("want enabled")
+	}
+
+	tests := []struct {
+		key   string
+		value string
+		want  string
+		ok    bool
+	}{
+		{key: "password", value: "p", want: Text, ok: true},
+		{key: "Password", value: "p", want: Text, ok: true},
+		{key: "user", value: "alice", ok: false},
+		{key: "access_token", value: "abc", want: Text, ok: true},
+		{key: "secret_key", value: "abc", want: Text, ok: true},
+		{key: "key_secret_", value: "abc", ok: false},
+		{key: "card", value: `"1234-5678"`, want: Text, ok: true},
+		{key: "[", value: "x", want: Text, ok: true},
+		{key: "password", value: Text, ok: false},
+		{key: "password", value: `"` + Text + `"`, ok: false},
+		{key: "password", value: "[REDACTED:0123456789abcdef]", ok: false},
+		{key: "password", value: "[REDACTEDhunter2", want: Text, ok: true},
+		{key: "password", value: `"[REDACTED hunter2"`, want: Text, ok: true},
+		{key: "password", value: "[REDACTED]hunter2", want: Text, ok: true},
+		{key: "password", value: `"[REDACTED]`, want: Text, ok: true},
+		{key: "password", value: "[REDACTED:hunter2]", want: Text, ok: true},
+		{key: "password", value: "[REDACTED:0123456789ABCDEF]", want: Text, ok: true},
+	}
+	for tn, tt := range tests {
+		got, ok := Redact(tt.key, []byte(tt.value))
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("%d: got=%q,%v want=%q,%v", tn, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRedactHash(t *testing.T) {
+	defer Reset()
+	Reset()
+	AddKeys("password")
+	SetHashKey([]byte("secret key"))
+
+	h1, _ := Redact("password", []byte("p1"))
+	h2, _ := Redact("password", []byte("p1"))
+	h3, _ := Redact("password", []byte("p2"))
+	if h1 != h2 {
+		t.Errorf("want same hash, got %q, %q", h1, h2)
+	}
+	if h1 == h3 {
+		t.Errorf("want different hash, got %q, %q", h1, h3)
+	}
+	if got, want := len(h1), len("[REDACTED:]")+16; got != want {
+		t.Errorf("got=%d, want=%d: %q", got, want, h1)
+	}
+	if _, ok := Redact("password", []byte(h1)); ok {
+		t.Errorf("want already redacted")
+	}
+
+	SetHashKey(nil)
+	if got, _ := Redact("password", []byte("p1")); got != Text {
+		t.Errorf("got=%q, want=%q", got, Text)
+	}
+}
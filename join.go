@@ -0,0 +1,131 @@
+package kv
+
+import (
+	"errors"
+	"strconv"
+)
+
+// joinedKey is the prefix for the keys of the key/value pairs
+// of each error combined by Join.
+const joinedKey = "errors"
+
+// Join returns an error that combines errs. Any nil errors are discarded,
+// and Join returns nil if every value in errs is nil.
+//
+// The error message starts with the number of errors, followed by the message
+// of each error separated by a semicolon and a space ("; "). The key/value
+// pairs of each error are kept, with keys prefixed by "errors" and the
+// position of the error, so that
+//
+//	kv.Join(
+//		kv.NewError("missing value").With("row", 1),
+//		kv.NewError("invalid value").With("row", 2),
+//	)
+//
+// renders as
+//
+//	2 errors: missing value; invalid value errors.0.row=1 errors.1.row=2
+//
+// Key/value pairs that apply to all of the errors can be attached
+// using the With method of the returned error. If only one error is
+// not nil, the combined error renders the same as that error, and has
+// the same code and public message (see Code and PublicMessage).
+//
+// The returned error has Is and As methods, so the errors.Is and
+// errors.As functions check each of the combined errors.
+func Join(errs ...error) Error {
+	var nonNil []error
+	for _, err := range errs {
+		if err != nil {
+			nonNil = append(nonNil, err)
+		}
+	}
+	if len(nonNil) == 0 {
+		return nil
+	}
+	e := newError(nil, nil)
+	e.errs = nonNil
+	return e
+}
+
+// Is reports whether any of the errors combined by Join matches target,
+// or whether an error returned by ParseError matches target. It is called
+// by the errors.Is function.
+func (e *errorT) Is(target error) bool {
+	if e.parsed && e.parsedIs(target) {
+		return true
+	}
+	for _, err := range e.errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors combined by Join that matches target.
+// It is called by the errors.As function.
+func (e *errorT) As(target interface{}) bool {
+	for _, err := range e.errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// joinedParts returns the message text and key/value pairs for
+// errors combined by Join.
+func joinedParts(errs []error) (string, List) {
+	if len(errs) == 1 {
+		return errorParts(errs[0])
+	}
+	var (
+		text = strconv.Itoa(len(errs)) + " errors: "
+		list List
+	)
+	for i, err := range errs {
+		childText, childList := errorParts(err)
+		if i > 0 {
+			text += "; "
+		}
+		text += childText
+		prefix := joinedKey + keySeparator + strconv.Itoa(i) + keySeparator
+		for j := 0; j < len(childList); j += 2 {
+			key, _ := childList[j].(string)
+			list = append(list, prefix+key, childList[j+1])
+		}
+	}
+	return text, list
+}
+
+// Errors collects errors, and combines them into a single error.
+// It is useful when an operation should report all of the failures
+// it encounters, rather than stopping at the first one. The zero value
+// is ready to use.
+type Errors struct {
+	errs []error
+}
+
+// Add adds err to the collected errors, with any key/value pairs
+// attached. If err is nil, it is ignored.
+func (e *Errors) Add(err error, keyvals ...interface{}) {
+	if err == nil {
+		return
+	}
+	if len(keyvals) > 0 {
+		err = Wrap(err).With(keyvals...)
+	}
+	e.errs = append(e.errs, err)
+}
+
+// Len returns the number of errors collected.
+func (e *Errors) Len() int {
+	return len(e.errs)
+}
+
+// Err returns an error that combines the collected errors, or nil if
+// no errors have been collected. See the Join function.
+func (e *Errors) Err() Error {
+	return Join(e.errs...)
+}
@@ -0,0 +1,98 @@
+package kv
+
+import (
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestJoin(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{
+			err:  NewError("first").With("a", 1),
+			want: "first a=1",
+		},
+		{
+			err:  Join(nil, NewError("first").With("a", 1), nil),
+			want: "first a=1",
+		},
+		{
+			err: Join(
+				NewError("missing value").With("row", 1, "field", "name"),
+				errors.New("invalid value"),
+				Wrap(io.EOF, "read failed").With("row", 3),
+			),
+			want: "3 errors: missing value; invalid value; read failed: EOF errors.0.row=1 errors.0.field=name errors.2.row=3",
+		},
+		{
+			err:  Join(NewError("first"), NewError("second").With("b", 2)).With("batch", 7),
+			want: "2 errors: first; second batch=7 errors.1.b=2",
+		},
+		{
+			err:  Wrap(Join(NewError("first"), NewError("second")), "import failed"),
+			want: "import failed: 2 errors: first; second",
+		},
+	}
+	for tn, tt := range tests {
+		if got, want := tt.err.Error(), tt.want; got != want {
+			t.Errorf("%d:\n got=%v\nwant=%v", tn, got, want)
+		}
+	}
+}
+
+func TestJoinNil(t *testing.T) {
+	if err := Join(); err != nil {
+		t.Errorf("got=%v, want=nil", err)
+	}
+	if err := Join(nil, nil); err != nil {
+		t.Errorf("got=%v, want=nil", err)
+	}
+	var errs Errors
+	if err := errs.Err(); err != nil {
+		t.Errorf("got=%v, want=nil", err)
+	}
+}
+
+func TestJoinIsAs(t *testing.T) {
+	pathErr := &os.PathError{Op: "open", Path: "/etc/passwd", Err: os.ErrPermission}
+	err := Wrap(Join(NewError("first"), pathErr), "second")
+
+	if !errors.Is(err, os.ErrPermission) {
+		t.Errorf("want errors.Is to match os.ErrPermission")
+	}
+	if errors.Is(err, io.EOF) {
+		t.Errorf("want errors.Is not to match io.EOF")
+	}
+	var target *os.PathError
+	if !errors.As(err, &target) {
+		t.Fatalf("want errors.As to match *os.PathError")
+	}
+	if got, want := target, pathErr; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var errs Errors
+	for row, value := range []string{"a", "", "c", ""} {
+		if value == "" {
+			errs.Add(NewError("missing value"), "row", row)
+		}
+	}
+	errs.Add(nil)
+	if got, want := errs.Len(), 2; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+	err := errs.Err()
+	if got, want := err.Error(), "2 errors: missing value; missing value errors.0.row=1 errors.1.row=3"; got != want {
+		t.Errorf("\n got=%v\nwant=%v", got, want)
+	}
+	if got, want := Fields(err), (List{"errors.0.row", 1, "errors.1.row", 3}); !reflect.DeepEqual(got, want) {
+		t.Errorf("\n got=%v\nwant=%v", got, want)
+	}
+}
@@ -0,0 +1,324 @@
+package kv
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jjeffery/kv/internal/logfmt"
+)
+
+// MarshalJSON implements the json.Marshaler interface.
+//
+// The list is rendered as a JSON object, with the keys in the order
+// that they first appear in the list. Identical values for the same
+// key are only rendered once. If a key has more than one distinct value,
+// it appears once in the JSON object with its values in a JSON array.
+// For example the list
+//
+//	"a", 1, "b", 2, "a", 3, "b", 2
+//
+// is rendered as
+//
+//	{"a":[1,3],"b":2}
+//
+// Values that implement the error interface are rendered as the error
+// message (including errors created by this package, which render as a
+// JSON object when marshaled directly), and values that implement fmt.Stringer (but do not otherwise
+// know how to render themselves as JSON) are rendered as strings. Values
+// that match the redaction rules (see RedactKeys and RedactValues) are
+// replaced in the same way as when the list is rendered as text.
+func (l List) MarshalJSON() ([]byte, error) {
+	type entryT struct {
+		key    string
+		values []interface{}
+	}
+	var (
+		entries []*entryT
+		index   = make(map[string]*entryT)
+	)
+	dedup(l).Range(func(key string, value interface{}) bool {
+		entry, ok := index[key]
+		if !ok {
+			entry = &entryT{key: key}
+			index[key] = entry
+			entries = append(entries, entry)
+		}
+		entry.values = append(entry.values, value)
+		return true
+	})
+
+	var buf bytes.Buffer
+	buf.WriteRune('{')
+	for i, entry := range entries {
+		if i > 0 {
+			buf.WriteRune(',')
+		}
+		if err := writeJSONValue(&buf, entry.key); err != nil {
+			return nil, err
+		}
+		buf.WriteRune(':')
+		if len(entry.values) == 1 {
+			if err := writeJSONField(&buf, entry.key, entry.values[0]); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		buf.WriteRune('[')
+		for j, value := range entry.values {
+			if j > 0 {
+				buf.WriteRune(',')
+			}
+			if err := writeJSONField(&buf, entry.key, value); err != nil {
+				return nil, err
+			}
+		}
+		buf.WriteRune(']')
+	}
+	buf.WriteRune('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+//
+// The JSON text must be an object or null. The keys in the JSON object
+// become the keys in the list, in the same order. Nested objects are
+// flattened, and their keys are prefixed with the key of the nested object
+// and a period, so that
+//
+//	{"http":{"method":"GET","status":200}}
+//
+// becomes the list
+//
+//	"http.method", "GET", "http.status", 200
+//
+// A JSON array results in one key/value pair for each item in the array,
+// all with the same key. This is the reverse of how MarshalJSON renders
+// keys with more than one value.
+//
+// JSON numbers are converted to int64 if they are integers, and float64
+// otherwise.
+func (l *List) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	tok, err := dec.Token()
+	if err != nil {
+		return Wrap(err, "cannot unmarshal JSON into kv.List")
+	}
+	var list List
+	if tok != nil {
+		if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+			return NewError("cannot unmarshal JSON into kv.List").With("token", fmt.Sprint(tok))
+		}
+		list, err = decodeJSONObject(dec, "", nil)
+		if err != nil {
+			return Wrap(err, "cannot unmarshal JSON into kv.List")
+		}
+	}
+	// there must be nothing after the object
+	if tok, err := dec.Token(); err != io.EOF {
+		if err != nil {
+			return Wrap(err, "cannot unmarshal JSON into kv.List")
+		}
+		err = NewError("unexpected data after object").With("token", fmt.Sprint(tok))
+		return Wrap(err, "cannot unmarshal JSON into kv.List")
+	}
+	*l = list
+	return nil
+}
+
+// writeJSONField writes the value associated with key in JSON format
+// to buf. If the key or value matches any of the redaction rules, the
+// replacement text is written instead.
+func writeJSONField(buf *bytes.Buffer, key string, value interface{}) error {
+	// resolve once, so that a lazy value is not evaluated twice
+	value = logfmt.Resolve(value)
+	if replacement, ok := logfmt.Redact(key, value); ok {
+		value = replacement
+	}
+	return writeJSONValue(buf, value)
+}
+
+// writeJSONValue writes the value in JSON format to buf.
+func writeJSONValue(buf *bytes.Buffer, value interface{}) error {
+	value = logfmt.Resolve(value)
+	switch v := value.(type) {
+	case error:
+		// checked first, as errors created by this package are
+		// json.Marshalers, but are rendered as their message here
+		value = v.Error()
+	case json.Marshaler, encoding.TextMarshaler:
+		// let the JSON package handle types that know how to render themselves
+	case fmt.Stringer:
+		value = v.String()
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		b, err = json.Marshal(fmt.Sprint(value))
+		if err != nil {
+			return err
+		}
+	}
+	buf.Write(b)
+	return nil
+}
+
+// decodeJSONObject appends the key/value pairs of a JSON object to list.
+// The opening delimiter has already been read from dec.
+func decodeJSONObject(dec *json.Decoder, prefix string, list List) (List, error) {
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+		list, err = decodeJSONValue(dec, prefix+key, list)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// read the closing delimiter
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// decodeJSONValue appends the next JSON value in dec to list.
+func decodeJSONValue(dec *json.Decoder, key string, list List) (List, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	switch v := tok.(type) {
+	case json.Delim:
+		if v == '{' {
+			return decodeJSONObject(dec, key+keySeparator, list)
+		}
+		// v == '['
+		for dec.More() {
+			list, err = decodeJSONValue(dec, key, list)
+			if err != nil {
+				return nil, err
+			}
+		}
+		// read the closing delimiter
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			list = append(list, key, n)
+		} else {
+			f, _ := v.Float64()
+			list = append(list, key, f)
+		}
+	default:
+		// string, bool or nil
+		list = append(list, key, v)
+	}
+	return list, nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+//
+// The error is rendered as a JSON object with the message text of the
+// error in "msg", its code (if any) in "code", its public message (if any)
+// in "public" and its key/value pairs in "fields". The error that it wraps
+// (if any) is rendered in "cause", and errors combined by Join are rendered
+// in "errors". For example
+//
+//	err := kv.NewError("permission denied").With("user", "alice")
+//	err = kv.Wrap(err, "cannot open file").With("file", "/etc/passwd")
+//
+// is rendered as
+//
+//	{"msg":"cannot open file","fields":{"file":"/etc/passwd"},"cause":{"msg":"permission denied","fields":{"user":"alice"}}}
+//
+// Errors not created by this package are rendered as an object with
+// their error message in "msg", and the error that they wrap (if any)
+// in "cause".
+//
+// The values in "fields" are subject to the redaction rules in the same
+// way as for List.MarshalJSON.
+func (e *errorT) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := e.writeJSON(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeJSON writes the error in JSON format to buf.
+func (e *errorT) writeJSON(buf *bytes.Buffer) error {
+	buf.WriteString(`{"msg":`)
+	if err := writeJSONValue(buf, strings.TrimSpace(e.text)); err != nil {
+		return err
+	}
+	if e.code != "" {
+		buf.WriteString(`,"code":`)
+		if err := writeJSONValue(buf, e.code); err != nil {
+			return err
+		}
+	}
+	if e.public != "" {
+		buf.WriteString(`,"public":`)
+		if err := writeJSONValue(buf, e.public); err != nil {
+			return err
+		}
+	}
+	if list := dedupPolicy(e.dup, append([]List{e.list}, e.ctxlists...)...); len(list) > 0 {
+		buf.WriteString(`,"fields":`)
+		b, err := list.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+	}
+	if e.err != nil {
+		buf.WriteString(`,"cause":`)
+		if err := writeJSONError(buf, e.err); err != nil {
+			return err
+		}
+	}
+	if len(e.errs) > 0 {
+		buf.WriteString(`,"errors":[`)
+		for i, err := range e.errs {
+			if i > 0 {
+				buf.WriteRune(',')
+			}
+			if err := writeJSONError(buf, err); err != nil {
+				return err
+			}
+		}
+		buf.WriteRune(']')
+	}
+	buf.WriteRune('}')
+	return nil
+}
+
+// writeJSONError writes err in JSON format to buf.
+func writeJSONError(buf *bytes.Buffer, err error) error {
+	if e := asErrorT(err); e != nil {
+		return e.writeJSON(buf)
+	}
+	buf.WriteString(`{"msg":`)
+	if err := writeJSONValue(buf, err.Error()); err != nil {
+		return err
+	}
+	if cause := errors.Unwrap(err); cause != nil {
+		buf.WriteString(`,"cause":`)
+		if err := writeJSONError(buf, cause); err != nil {
+			return err
+		}
+	}
+	buf.WriteRune('}')
+	return nil
+}
@@ -0,0 +1,181 @@
+package kv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestListMarshalJSON(t *testing.T) {
+	tm := time.Date(2099, 12, 31, 12, 34, 56, 0, time.UTC)
+	tests := []struct {
+		list List
+		want string
+	}{
+		{
+			list: nil,
+			want: `{}`,
+		},
+		{
+			list: List{"z", 1, "a", "two", "m", true},
+			want: `{"z":1,"a":"two","m":true}`,
+		},
+		{
+			list: List{"a", 1, "b", 2, "a", 3, "b", 2},
+			want: `{"a":[1,3],"b":2}`,
+		},
+		{
+			list: List{"a", 1, List{"b", 2, "c", nil}},
+			want: `{"a":1,"b":2,"c":null}`,
+		},
+		{
+			list: List{"msg", "message text", errors.New("the error")},
+			want: `{"msg":"message text","error":"the error"}`,
+		},
+		{
+			list: List{"err", NewError("boom").With("x", 1)},
+			want: `{"err":"boom x=1"}`,
+		},
+		{
+			list: List{"time", tm, "complex", complex(1, 2)},
+			want: `{"time":"2099-12-31T12:34:56Z","complex":"(1+2i)"}`,
+		},
+	}
+	for tn, tt := range tests {
+		b, err := json.Marshal(tt.list)
+		if err != nil {
+			t.Errorf("%d: %v", tn, err)
+			continue
+		}
+		if got, want := string(b), tt.want; got != want {
+			t.Errorf("%d:\n got=%v\nwant=%v", tn, got, want)
+		}
+	}
+}
+
+func TestListUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    List
+		wantErr bool
+	}{
+		{
+			text: `null`,
+			want: nil,
+		},
+		{
+			text: `{}`,
+			want: nil,
+		},
+		{
+			text: `{"z":1,"a":"two","m":true,"f":1.5,"n":null}`,
+			want: List{"z", int64(1), "a", "two", "m", true, "f", 1.5, "n", nil},
+		},
+		{
+			text: `{"a":[1,3],"b":2}`,
+			want: List{"a", int64(1), "a", int64(3), "b", int64(2)},
+		},
+		{
+			text: `{"http":{"method":"GET","status":200,"headers":{"accept":"*/*"}},"a":1}`,
+			want: List{
+				"http.method", "GET",
+				"http.status", int64(200),
+				"http.headers.accept", "*/*",
+				"a", int64(1),
+			},
+		},
+		{
+			text:    `[1,2]`,
+			wantErr: true,
+		},
+		{
+			text:    `{"a":`,
+			wantErr: true,
+		},
+		{
+			text:    `{"a":1} junk`,
+			wantErr: true,
+		},
+		{
+			text:    `{"a":1}{"b":2}`,
+			wantErr: true,
+		},
+		{
+			text:    `null 1`,
+			wantErr: true,
+		},
+		{
+			text: " {\"a\":1}\n",
+			want: List{"a", int64(1)},
+		},
+	}
+	for tn, tt := range tests {
+		// called directly, as json.Unmarshal checks for trailing data itself
+		var list List
+		err := list.UnmarshalJSON([]byte(tt.text))
+		if got, want := err != nil, tt.wantErr; got != want {
+			t.Errorf("%d: got=%v, want=%v: %v", tn, got, want, err)
+			continue
+		}
+		if got, want := list, tt.want; !reflect.DeepEqual(got, want) {
+			t.Errorf("%d:\n got=%v\nwant=%v", tn, got, want)
+		}
+	}
+}
+
+func TestListJSONRoundTrip(t *testing.T) {
+	list := List{"a", int64(1), "b", "two", "a", int64(3)}
+	b, err := json.Marshal(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got List
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := List{"a", int64(1), "a", int64(3), "b", "two"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\n got=%v\nwant=%v", got, want)
+	}
+}
+
+func TestErrorMarshalJSON(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{
+			err:  NewError("text"),
+			want: `{"msg":"text"}`,
+		},
+		{
+			err:  NewError("not found").With("id", 42, "at", time.Date(2099, 12, 31, 0, 0, 0, 0, time.UTC)).WithCode(NotFound),
+			want: `{"msg":"not found","code":"not_found","fields":{"id":42,"at":"2099-12-31T00:00:00Z"}}`,
+		},
+		{
+			err:  Wrap(NewError("permission denied").With("user", "alice"), "cannot open file").With("file", "/etc/passwd"),
+			want: `{"msg":"cannot open file","fields":{"file":"/etc/passwd"},"cause":{"msg":"permission denied","fields":{"user":"alice"}}}`,
+		},
+		{
+			err:  Wrap(fmt.Errorf("foreign: %w", NewError("inner").With("a", 1))),
+			want: `{"msg":"","cause":{"msg":"foreign: inner a=1","cause":{"msg":"inner","fields":{"a":1}}}}`,
+		},
+		{
+			err:  Join(NewError("first").With("row", 1), errors.New("second")).With("batch", 7),
+			want: `{"msg":"","fields":{"batch":7},"errors":[{"msg":"first","fields":{"row":1}},{"msg":"second"}]}`,
+		},
+	}
+	for tn, tt := range tests {
+		b, err := json.Marshal(tt.err)
+		if err != nil {
+			t.Errorf("%d: %v", tn, err)
+			continue
+		}
+		if got, want := string(b), tt.want; got != want {
+			t.Errorf("%d:\n got=%v\nwant=%v", tn, got, want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package kv
+
+import (
+	"context"
+
+	"github.com/jjeffery/kv/internal/logfmt"
+)
+
+// Key is a key whose values have type T. Declaring the keys used by a
+// program as typed keys provides a single place where the keys are named,
+// and ensures at compile time that each key is paired with a value of
+// the correct type:
+//
+//	var UserID = kv.Key[int64]("user_id")
+//
+//	ctx = kv.From(ctx).With(UserID.V(42))
+//
+//	// ... later
+//	if userID, ok := UserID.From(ctx); ok {
+//		// userID is an int64
+//	}
+type Key[T any] string
+
+// V returns a key/value pair with the key and value. The pair can be
+// passed anywhere that key/value pairs are accepted, and is always treated
+// as a single key/value pair.
+func (k Key[T]) V(value T) Pair {
+	return Pair{Key: string(k), Value: value}
+}
+
+// String returns the key as a string.
+func (k Key[T]) String() string {
+	return string(k)
+}
+
+// Lookup returns the value associated with the key in list, and reports
+// whether the key was found with a value of type T. If the key appears
+// more than once in the list, the first value is used. A lazy value
+// (see Valuer) is evaluated, and it is the result that must have type T.
+func (k Key[T]) Lookup(list List) (T, bool) {
+	return k.value(list.Lookup(string(k)))
+}
+
+// From returns the value associated with the key in the context, and
+// reports whether the key was found with a value of type T. If the key
+// has been attached to the context more than once, the most recently
+// attached value is used, unless the context's DupPolicy is FirstWins.
+//
+// Only the value found is evaluated: any lazy values attached to the
+// context for other keys are not.
+func (k Key[T]) From(ctx context.Context) (T, bool) {
+	return k.lookupLists(listsFromContext(ctx))
+}
+
+// FromError returns the value associated with the key in the error or
+// any error that it wraps, and reports whether the key was found with a
+// value of type T. The value attached most recently takes precedence,
+// unless the error's DupPolicy is FirstWins. See also the Fields function.
+func (k Key[T]) FromError(err error) (T, bool) {
+	return k.lookupLists(fieldLists(err))
+}
+
+// lookupLists returns the value associated with the key in lists, which
+// are ordered most recent first. The most recent value is used, unless
+// dup is FirstWins. Only the value found is evaluated.
+func (k Key[T]) lookupLists(lists []List, dup DupPolicy) (T, bool) {
+	key := string(k)
+	if dup.resolve() == FirstWins {
+		// the last list has the oldest values
+		for i := len(lists) - 1; i >= 0; i-- {
+			if v, ok := lists[i].Lookup(key); ok {
+				return k.value(v, true)
+			}
+		}
+		return k.value(nil, false)
+	}
+	// the first list has the most recent values,
+	// and the last value in each list is the most recent
+	for _, list := range lists {
+		var value interface{}
+		var found bool
+		list.Range(func(name string, v interface{}) bool {
+			if name == key {
+				value, found = v, true
+			}
+			return true
+		})
+		if found {
+			return k.value(value, true)
+		}
+	}
+	return k.value(nil, false)
+}
+
+// value returns v as type T, if possible.
+func (k Key[T]) value(v interface{}, found bool) (T, bool) {
+	var zero T
+	if !found {
+		return zero, false
+	}
+	// lazy values are evaluated first, so that the result is the
+	// same for every T, including interface types
+	if t, ok := logfmt.Resolve(v).(T); ok {
+		return t, true
+	}
+	return zero, false
+}
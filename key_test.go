@@ -0,0 +1,175 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKey(t *testing.T) {
+	var (
+		userID  = Key[int64]("user_id")
+		created = Key[time.Time]("created")
+		name    = Key[string]("name")
+	)
+	tm := time.Date(2099, 12, 31, 12, 34, 56, 0, time.UTC)
+
+	list := With(userID.V(42), "other", 1, created.V(tm))
+	if got, want := list, (List{"user_id", int64(42), "other", 1, "created", tm}); !reflect.DeepEqual(got, want) {
+		t.Errorf("\n got=%v\nwant=%v", got, want)
+	}
+	if got, want := list.String(), "user_id=42 other=1 created=\"2099-12-31T12:34:56Z\""; got != want {
+		t.Errorf("\n got=%v\nwant=%v", got, want)
+	}
+
+	if got, ok := userID.Lookup(list); !ok || got != 42 {
+		t.Errorf("got=%v, %v, want=42, true", got, ok)
+	}
+	if got, ok := created.Lookup(list); !ok || !got.Equal(tm) {
+		t.Errorf("got=%v, %v, want=%v, true", got, ok, tm)
+	}
+	if got, ok := name.Lookup(list); ok || got != "" {
+		t.Errorf("got=%v, %v, want=blank, false", got, ok)
+	}
+
+	// wrong type
+	if got, ok := userID.Lookup(List{"user_id", "42"}); ok || got != 0 {
+		t.Errorf("got=%v, %v, want=0, false", got, ok)
+	}
+
+	// lazy value
+	lazy := Lazy(func() interface{} { return int64(43) })
+	if got, ok := userID.Lookup(List{"user_id", lazy}); !ok || got != 43 {
+		t.Errorf("got=%v, %v, want=43, true", got, ok)
+	}
+	if got, ok := Key[interface{}]("user_id").Lookup(List{"user_id", lazy}); !ok || got != int64(43) {
+		t.Errorf("got=%v, %v, want=43, true", got, ok)
+	}
+	if got, ok := Key[fmt.Stringer]("user_id").Lookup(List{"user_id", lazy}); ok || got != nil {
+		t.Errorf("got=%v, %v, want=nil, false", got, ok)
+	}
+}
+
+func TestKeyFrom(t *testing.T) {
+	userID := Key[int64]("user_id")
+
+	ctx := context.Background()
+	if got, ok := userID.From(ctx); ok || got != 0 {
+		t.Errorf("got=%v, %v, want=0, false", got, ok)
+	}
+	ctx = From(ctx).With(userID.V(1))
+	ctx = From(ctx).With("a", 1)
+	if got, ok := userID.From(ctx); !ok || got != 1 {
+		t.Errorf("got=%v, %v, want=1, true", got, ok)
+	}
+	ctx2 := From(ctx).With(userID.V(2))
+	if got, ok := userID.From(ctx2); !ok || got != 2 {
+		t.Errorf("got=%v, %v, want=2, true", got, ok)
+	}
+	ctx3 := From(ctx).With(FirstWins, userID.V(3))
+	if got, ok := userID.From(ctx3); !ok || got != 1 {
+		t.Errorf("got=%v, %v, want=1, true", got, ok)
+	}
+}
+
+func TestKeyFromError(t *testing.T) {
+	userID := Key[int64]("user_id")
+
+	err := Wrap(NewError("first").With(userID.V(1)), "second").With("a", 1)
+	if got, ok := userID.FromError(err); !ok || got != 1 {
+		t.Errorf("got=%v, %v, want=1, true", got, ok)
+	}
+	err = Wrap(err, "third").With(userID.V(2))
+	if got, ok := userID.FromError(err); !ok || got != 2 {
+		t.Errorf("got=%v, %v, want=2, true", got, ok)
+	}
+	if got, ok := userID.FromError(errors.New("text user_id=3")); ok || got != 0 {
+		t.Errorf("got=%v, %v, want=0, false", got, ok)
+	}
+
+	// same key set twice on one error
+	err = NewError("x").With(userID.V(1)).With(userID.V(2))
+	if got, ok := userID.FromError(err); !ok || got != 2 {
+		t.Errorf("got=%v, %v, want=2, true", got, ok)
+	}
+	err = Wrap(err, "y").With(userID.V(3))
+	if got, ok := userID.FromError(err); !ok || got != 3 {
+		t.Errorf("got=%v, %v, want=3, true", got, ok)
+	}
+	err = NewError("x").With(FirstWins, userID.V(1)).With(userID.V(2))
+	if got, ok := userID.FromError(err); !ok || got != 1 {
+		t.Errorf("got=%v, %v, want=1, true", got, ok)
+	}
+	err = Wrap(fmt.Errorf("foreign: %w", NewError("x").With(userID.V(1), userID.V(2))))
+	if got, ok := userID.FromError(err); !ok || got != 2 {
+		t.Errorf("got=%v, %v, want=2, true", got, ok)
+	}
+	if got, ok := Key[string]("code").FromError(NewError("x").WithCode(NotFound)); !ok || got != NotFound {
+		t.Errorf("got=%v, %v, want=%v, true", got, ok, NotFound)
+	}
+}
+
+func TestKeyNotExpanded(t *testing.T) {
+	type addr struct {
+		City string
+	}
+	var (
+		home  = Key[addr]("home")
+		attrs = Key[map[string]string]("attrs")
+	)
+	list := With(home.V(addr{City: "Paris"}), attrs.V(map[string]string{"a": "b"}))
+	if got, want := list.String(), `home="{Paris}" attrs="map[a:b]"`; got != want {
+		t.Errorf("\n got=%v\nwant=%v", got, want)
+	}
+	if got, want := list.With("x", 1).String(), `home="{Paris}" attrs="map[a:b]" x=1`; got != want {
+		t.Errorf("\n got=%v\nwant=%v", got, want)
+	}
+
+	ctx := From(context.Background()).With(home.V(addr{City: "Paris"}))
+	ctx = From(ctx).With("a", 1)
+	if got, ok := home.From(ctx); !ok || got.City != "Paris" {
+		t.Errorf("got=%v, %v, want={Paris}, true", got, ok)
+	}
+	if got, ok := attrs.Lookup(list); !ok || got["a"] != "b" {
+		t.Errorf("got=%v, %v, want=map[a:b], true", got, ok)
+	}
+	err := NewError("x").With(home.V(addr{City: "Paris"}))
+	if got, ok := home.FromError(err); !ok || got.City != "Paris" {
+		t.Errorf("got=%v, %v, want={Paris}, true", got, ok)
+	}
+	if got, want := err.Error(), `x home="{Paris}"`; got != want {
+		t.Errorf("\n got=%v\nwant=%v", got, want)
+	}
+
+	var logged string
+	defer func(output func(int, string) error) { LogOutput = output }(LogOutput)
+	LogOutput = func(_ int, s string) error {
+		logged = s
+		return nil
+	}
+	Log("message", home.V(addr{City: "Paris"}))
+	if got, want := logged, "message home=\"{Paris}\"\n"; got != want {
+		t.Errorf("\n got=%q\nwant=%q", got, want)
+	}
+}
+
+func TestKeyFromLazy(t *testing.T) {
+	userID := Key[int64]("user_id")
+
+	var calls int
+	lazy := Lazy(func() interface{} {
+		calls++
+		return "expensive"
+	})
+	ctx := From(context.Background()).With("payload", lazy, userID.V(1), userID.V(2))
+	ctx = From(ctx).With("a", 1)
+	if got, ok := userID.From(ctx); !ok || got != 2 {
+		t.Errorf("got=%v, %v, want=2, true", got, ok)
+	}
+	if calls != 0 {
+		t.Errorf("got=%d calls, want=0", calls)
+	}
+}
@@ -0,0 +1,181 @@
+package kv
+
+import (
+	"context"
+	"regexp"
+)
+
+// KeyPolicy determines the names given to keys that are missing
+// from a list of key/value pairs.
+//
+// When a list does not consist of alternating keys and values, the
+// list is fixed by inserting keys where they appear to be missing.
+// For example, the list
+//
+//	"cannot open file", "file", "/etc/passwd", io.EOF
+//
+// is fixed to become
+//
+//	"msg", "cannot open file", "file", "/etc/passwd", "error", io.EOF
+type KeyPolicy struct {
+	// MsgKey is the key given to the first string value that does not
+	// have a key, unless the list already contains a MsgKey key. If there
+	// is no message, the first error value without a key is given this
+	// key instead. If MsgKey is blank, no message key is inferred.
+	MsgKey string
+
+	// ErrorKey is the key given to error values that do not have a key,
+	// once the list has a message. If ErrorKey is blank, these error values
+	// are named the same way as other missing keys.
+	ErrorKey string
+
+	// MissingPrefix is the prefix for the names of any other missing keys,
+	// which are numbered from left to right (eg "_p1", "_p2", ...). If
+	// MissingPrefix is blank, the prefix "_p" is used.
+	MissingPrefix string
+
+	// KnownKeys are strings that are always considered to be keys
+	// when deciding where keys are missing.
+	KnownKeys []string
+
+	// PossibleKey matches strings that are more likely to be a key than
+	// a value. It is used when deciding where keys are missing. If PossibleKey
+	// is nil, strings that are not in KnownKeys are considered likely to be values.
+	PossibleKey *regexp.Regexp
+}
+
+// DefaultKeyPolicy is the policy for naming missing keys used by the
+// With function, and by the List, Context and Error types, except for
+// those created from a PolicyList. It should only be modified during
+// program initialization.
+var DefaultKeyPolicy = &KeyPolicy{
+	MsgKey:        "msg",
+	ErrorKey:      "error",
+	MissingPrefix: "_p",
+	KnownKeys:     []string{"msg", "level", "id"},
+	PossibleKey:   regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.]*$`),
+}
+
+// With returns a list populated with keyvals as the key/value pairs.
+// Any missing keys are named according to the policy, which makes
+// it possible to use a different policy for an individual list.
+//
+// The returned list keeps the policy, so it also applies to key/value
+// pairs added later using its With method, and to errors and contexts
+// created from the list.
+func (p *KeyPolicy) With(keyvals ...interface{}) PolicyList {
+	return PolicyList{
+		List:   List(flattenFixPolicy(keyvals, keyPolicy(p))),
+		Policy: p,
+	}
+}
+
+// PolicyList is a list of key/value pairs with its own policy for naming
+// missing keys. It is created by the KeyPolicy.With method.
+//
+// The policy applies to key/value pairs added using its With method. It
+// also applies to key/value pairs added to errors created by its NewError
+// and Wrap methods, and to contexts created by its From method (and any
+// contexts derived from them). If Policy is nil, DefaultKeyPolicy is used.
+type PolicyList struct {
+	List
+	Policy *KeyPolicy
+}
+
+// With returns a new list with keyvals appended, and with the same
+// policy. The original list (l) is not modified.
+func (l PolicyList) With(keyvals ...interface{}) PolicyList {
+	return PolicyList{
+		List:   l.List.withPolicy(keyPolicy(l.Policy), keyvals),
+		Policy: l.Policy,
+	}
+}
+
+// Group returns a new list with the same key/value pairs and policy
+// as the list (l), except that each key is prefixed with the group name
+// and a period. See List.Group.
+func (l PolicyList) Group(name string) PolicyList {
+	return PolicyList{
+		List:   l.List.Group(name),
+		Policy: l.Policy,
+	}
+}
+
+// From returns a new context with key/value pairs copied both from
+// the list and the context. The policy applies to key/value pairs
+// attached to the new context later.
+func (l PolicyList) From(ctx context.Context) Context {
+	ctx = newContext(ctx, l.policy(), l.List)
+	return &contextT{ctx: ctx}
+}
+
+// NewError returns an error with the given message and a list of
+// key/value pairs copied from the list. The policy applies to key/value
+// pairs attached to the error later.
+func (l PolicyList) NewError(text string) Error {
+	e := newError(nil, nil, text)
+	e.list = l.List
+	e.keys = l.policy()
+	return e
+}
+
+// Wrap wraps the error with the key/value pairs copied from the list,
+// and the optional text. The policy applies to key/value pairs attached
+// to the error later.
+func (l PolicyList) Wrap(err error, text ...string) Error {
+	e := newError(nil, err, text...)
+	e.list = l.List
+	e.keys = l.policy()
+	return causer(e)
+}
+
+// Log is used to log a message. See List.Log.
+func (l PolicyList) Log(args ...interface{}) {
+	logHelper(2, l.List, args...)
+}
+
+// policy returns the policy for the list. It is never nil, so that
+// contexts and errors created from the list keep it.
+func (l PolicyList) policy() *KeyPolicy {
+	if l.Policy == nil {
+		return DefaultKeyPolicy
+	}
+	return l.Policy
+}
+
+// keyPolicy returns p, or DefaultKeyPolicy if p is nil.
+func keyPolicy(p *KeyPolicy) *KeyPolicy {
+	if p == nil {
+		return DefaultKeyPolicy
+	}
+	return p
+}
+
+func (p *KeyPolicy) isKnownKey(s string) bool {
+	for _, key := range p.KnownKeys {
+		if key == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *KeyPolicy) isPossibleKey(s string) bool {
+	return p.PossibleKey != nil && p.PossibleKey.MatchString(s)
+}
+
+func (p *KeyPolicy) missingPrefix() string {
+	if p.MissingPrefix == "" {
+		return "_p"
+	}
+	return p.MissingPrefix
+}
+
+// msgKey returns the key used for message text when converting
+// from text to key/value pairs.
+func msgKey() string {
+	if p := DefaultKeyPolicy; p != nil && p.MsgKey != "" {
+		return p.MsgKey
+	}
+	return "msg"
+}
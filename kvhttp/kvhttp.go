@@ -0,0 +1,98 @@
+// Package kvhttp provides support for using key/value pairs
+// with HTTP clients and servers.
+package kvhttp
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/jjeffery/kv"
+)
+
+// problemContentType is the media type for problem details.
+const problemContentType = "application/problem+json"
+
+// problem is the problem details object described in RFC 7807.
+type problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string  `json:"detail,omitempty"`
+	Code   string  `json:"code,omitempty"`
+	Fields kv.List `json:"fields,omitempty"`
+}
+
+// WriteProblem writes an HTTP response describing err in the
+// "application/problem+json" format described in RFC 7807.
+//
+// The response status is determined by the error code (see kv.HTTPStatus),
+// and the error code is written in the "code" extension member. The "detail"
+// member is the public message of the error (see kv.PublicMessage), and is
+// omitted if the error does not have a public message. The internal error
+// message and key/value pairs are not written, as they are intended for
+// logs rather than clients (see WriteProblemFields). If err is nil,
+// nothing is written.
+func WriteProblem(w http.ResponseWriter, err error) {
+	writeProblem(w, err, false, nil)
+}
+
+// WriteProblemFields is the same as WriteProblem, except that the key/value
+// pairs of the error are also written in the "fields" extension member.
+// If keys are specified, only the key/value pairs with those keys are
+// written. Otherwise all of the key/value pairs are written, so keys should
+// be specified unless the error is known not to contain details that
+// should not be shown to clients. Values are subject to the redaction
+// rules (see kv.RedactKeys).
+func WriteProblemFields(w http.ResponseWriter, err error, keys ...string) {
+	writeProblem(w, err, true, keys)
+}
+
+// writeProblem writes the problem details for err, including its
+// key/value pairs if fields is true. If keys is not empty, only the
+// key/value pairs with those keys are included.
+func writeProblem(w http.ResponseWriter, err error, fields bool, keys []string) {
+	if err == nil {
+		return
+	}
+	status := kv.HTTPStatus(err)
+	p := problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: kv.PublicMessage(err),
+		Code:   kv.Code(err),
+	}
+	if fields {
+		kv.Fields(err).Range(func(key string, value interface{}) bool {
+			// the code has its own member
+			if key != "code" && allowed(key, keys) {
+				p.Fields = append(p.Fields, key, value)
+			}
+			return true
+		})
+	}
+	b, jsonErr := json.Marshal(p)
+	if jsonErr != nil {
+		// should not happen, as kv.List renders values that cannot be
+		// marshaled as strings
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+// allowed reports whether key is in keys, or keys is empty.
+func allowed(key string, keys []string) bool {
+	if len(keys) == 0 {
+		return true
+	}
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
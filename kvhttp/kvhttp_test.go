@@ -0,0 +1,419 @@
+package kvhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jjeffery/kv"
+	"github.com/jjeffery/kv/internal/redact"
+)
+
+func TestWriteProblem(t *testing.T) {
+	tests := []struct {
+		err        error
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			err:        kv.NewError("user not found").With("user", "alice", "attempt", 3).WithCode(kv.NotFound).WithPublic("The user does not exist."),
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"type":"about:blank","title":"Not Found","status":404,"detail":"The user does not exist.","code":"not_found"}`,
+		},
+		{
+			err:        kv.Wrap(kv.NewError("bad value").WithCode(kv.Invalid).WithPublic("Value is not valid."), "cannot update").With("file", "/etc/passwd"),
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"about:blank","title":"Bad Request","status":400,"detail":"Value is not valid.","code":"invalid"}`,
+		},
+		{
+			err:        errors.New("something failed"),
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   `{"type":"about:blank","title":"Internal Server Error","status":500}`,
+		},
+	}
+	for tn, tt := range tests {
+		rec := httptest.NewRecorder()
+		WriteProblem(rec, tt.err)
+		if got, want := rec.Code, tt.wantStatus; got != want {
+			t.Errorf("%d: status: got=%v, want=%v", tn, got, want)
+		}
+		if got, want := rec.Header().Get("Content-Type"), "application/problem+json"; got != want {
+			t.Errorf("%d: content type: got=%v, want=%v", tn, got, want)
+		}
+		if got, want := rec.Body.String(), tt.wantBody; got != want {
+			t.Errorf("%d: body:\n got=%v\nwant=%v", tn, got, want)
+		}
+	}
+}
+
+func TestWriteProblemFields(t *testing.T) {
+	defer redact.Reset()
+	kv.RedactKeys("password")
+
+	err := kv.Wrap(kv.NewError("user not found").With("user", "alice", "password", "hunter2").WithCode(kv.NotFound), "cannot login").With("attempt", 3)
+	tests := []struct {
+		keys     []string
+		wantBody string
+	}{
+		{
+			keys:     nil,
+			wantBody: `{"type":"about:blank","title":"Not Found","status":404,"code":"not_found","fields":{"attempt":3,"user":"alice","password":"[REDACTED]"}}`,
+		},
+		{
+			keys:     []string{"user", "missing"},
+			wantBody: `{"type":"about:blank","title":"Not Found","status":404,"code":"not_found","fields":{"user":"alice"}}`,
+		},
+		{
+			keys:     []string{"missing"},
+			wantBody: `{"type":"about:blank","title":"Not Found","status":404,"code":"not_found"}`,
+		},
+	}
+	for tn, tt := range tests {
+		rec := httptest.NewRecorder()
+		WriteProblemFields(rec, err, tt.keys...)
+		if got, want := rec.Code, http.StatusNotFound; got != want {
+			t.Errorf("%d: status: got=%v, want=%v", tn, got, want)
+		}
+		if got, want := rec.Body.String(), tt.wantBody; got != want {
+			t.Errorf("%d: body:\n got=%v\nwant=%v", tn, got, want)
+		}
+	}
+}
+
+func TestWriteProblemNil(t *testing.T) {
+	rec := httptest.NewRecorder()
+	WriteProblem(rec, nil)
+	if got, want := rec.Body.Len(), 0; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var logged []string
+	defer func(output func(int, string) error) { kv.LogOutput = output }(kv.LogOutput)
+	kv.LogOutput = func(calldepth int, s string) error {
+		logged = append(logged, s)
+		return nil
+	}
+
+	tests := []struct {
+		handler    http.HandlerFunc
+		requestID  string
+		wantStatus int
+		wantBody   string
+		wantLogs   []string
+	}{
+		{
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				kv.Log("info: handling", r.Context())
+				w.Write([]byte("hello"))
+			},
+			requestID:  "abc123",
+			wantStatus: http.StatusOK,
+			wantBody:   "hello",
+			wantLogs: []string{
+				`info: handling method=GET path="/widgets" remote="192.0.2.1:1234" request_id=abc123`,
+				`info: request complete status=200 bytes=5 duration=`,
+			},
+		},
+		{
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "missing", http.StatusNotFound)
+			},
+			requestID:  "abc123",
+			wantStatus: http.StatusNotFound,
+			wantBody:   "missing\n",
+			wantLogs: []string{
+				`info: request complete status=404 bytes=8 duration=`,
+			},
+		},
+		{
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				panic("boom")
+			},
+			requestID:  "abc123",
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   `{"type":"about:blank","title":"Internal Server Error","status":500}`,
+			wantLogs: []string{
+				`error: panic panic=boom method=GET path="/widgets" remote="192.0.2.1:1234" request_id=abc123`,
+				`info: request complete status=500 bytes=67 duration=`,
+			},
+		},
+	}
+	for tn, tt := range tests {
+		logged = nil
+		r := httptest.NewRequest("GET", "/widgets", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		r.Header.Set("X-Request-Id", tt.requestID)
+		rec := httptest.NewRecorder()
+		Middleware(tt.handler).ServeHTTP(rec, r)
+		if got, want := rec.Code, tt.wantStatus; got != want {
+			t.Errorf("%d: status: got=%v, want=%v", tn, got, want)
+		}
+		if got, want := rec.Body.String(), tt.wantBody; got != want {
+			t.Errorf("%d: body:\n got=%v\nwant=%v", tn, got, want)
+		}
+		if got, want := rec.Header().Get("X-Request-Id"), tt.requestID; got != want {
+			t.Errorf("%d: request id: got=%v, want=%v", tn, got, want)
+		}
+		if got, want := len(logged), len(tt.wantLogs); got != want {
+			t.Errorf("%d: got %d log lines, want %d: %q", tn, got, want, logged)
+			continue
+		}
+		for i, want := range tt.wantLogs {
+			if got := logged[i]; !strings.HasPrefix(got, want) {
+				t.Errorf("%d: log %d:\n got=%v\nwant=%v", tn, i, got, want)
+			}
+		}
+	}
+}
+
+func TestMiddlewareRequestID(t *testing.T) {
+	defer func(output func(int, string) error) { kv.LogOutput = output }(kv.LogOutput)
+	kv.LogOutput = func(int, string) error { return nil }
+
+	tests := []struct {
+		requestID string
+		keep      bool
+	}{
+		{requestID: "", keep: false},
+		{requestID: "req-1", keep: true},
+		{requestID: "has space", keep: false},
+		{requestID: strings.Repeat("x", 129), keep: false},
+	}
+	for tn, tt := range tests {
+		var ctxID interface{}
+		h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctxID = kv.From(r.Context()).List().Get("request_id")
+		}))
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.requestID != "" {
+			r.Header.Set("X-Request-Id", tt.requestID)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		got := rec.Header().Get("X-Request-Id")
+		if got == "" {
+			t.Errorf("%d: missing request id", tn)
+		}
+		if ctxID != got {
+			t.Errorf("%d: context request id: got=%v, want=%v", tn, ctxID, got)
+		}
+		if (got == tt.requestID) != tt.keep {
+			t.Errorf("%d: got=%v, keep=%v", tn, got, tt.keep)
+		}
+	}
+}
+
+func TestMiddlewareAbortHandler(t *testing.T) {
+	defer func(output func(int, string) error) { kv.LogOutput = output }(kv.LogOutput)
+	kv.LogOutput = func(int, string) error { return nil }
+
+	defer func() {
+		if r := recover(); r != http.ErrAbortHandler {
+			t.Errorf("got=%v, want=%v", r, http.ErrAbortHandler)
+		}
+	}()
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	t.Error("want panic")
+}
+
+func TestMiddlewareHijack(t *testing.T) {
+	// the message is logged after the client has received the response
+	logged := make(chan string, 1)
+	defer func(output func(int, string) error) { kv.LogOutput = output }(kv.LogOutput)
+	kv.LogOutput = func(_ int, s string) error {
+		logged <- s
+		return nil
+	}
+
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := w.(http.Pusher).Push("/style.css", nil); err != http.ErrNotSupported {
+			t.Errorf("push: got=%v, want=%v", err, http.ErrNotSupported)
+		}
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n\r\n")
+		rw.Flush()
+	}))
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusSwitchingProtocols; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+	if got := <-logged; !strings.Contains(got, "status=101") {
+		t.Errorf("logged=%q", got)
+	}
+
+	// response writer that cannot be hijacked
+	h = Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, err := w.(http.Hijacker).Hijack(); err != http.ErrNotSupported {
+			t.Errorf("hijack: got=%v, want=%v", err, http.ErrNotSupported)
+		}
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	<-logged
+}
+
+func TestTransport(t *testing.T) {
+	var logged []string
+	defer func(output func(int, string) error) { kv.LogOutput = output }(kv.LogOutput)
+	kv.LogOutput = func(calldepth int, s string) error {
+		logged = append(logged, s)
+		return nil
+	}
+
+	var gotHeader http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Clone()
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer server.Close()
+
+	client := &http.Client{
+		Transport: &Transport{
+			Headers: map[string]string{
+				"request_id": "X-Request-Id",
+				"user":       "X-User",
+				"tenant":     "X-Tenant",
+			},
+		},
+	}
+	ctx := kv.From(context.Background()).With(
+		"request_id", "abc123",
+		"tenant", kv.Lazy(func() interface{} { panic("boom") }),
+	)
+	req, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/widgets", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got, want := resp.StatusCode, http.StatusTeapot; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+	if got, want := gotHeader.Get("X-Request-Id"), "abc123"; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+	if got, want := gotHeader.Get("X-User"), ""; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+	if got, want := gotHeader.Get("X-Tenant"), "PANIC"; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+	if got, want := req.Header.Get("X-Request-Id"), ""; got != want {
+		t.Errorf("original request modified: got=%v, want=%v", got, want)
+	}
+	if got, want := len(logged), 1; got != want {
+		t.Fatalf("got %d log lines, want %d", got, want)
+	}
+	want := fmt.Sprintf(`info: http request method=GET url="%s/widgets" status=418 elapsed=`, server.URL)
+	if got := logged[0]; !strings.HasPrefix(got, want) || !strings.Contains(got, "request_id=abc123") {
+		t.Errorf("\n got=%v\nwant=%v", got, want)
+	}
+}
+
+func TestTransportError(t *testing.T) {
+	var logged []string
+	defer func(output func(int, string) error) { kv.LogOutput = output }(kv.LogOutput)
+	kv.LogOutput = func(calldepth int, s string) error {
+		logged = append(logged, s)
+		return nil
+	}
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	client := &http.Client{Transport: &Transport{}}
+	ctx := kv.From(context.Background()).With("request_id", "abc123")
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Do(req)
+	if err == nil {
+		t.Fatal("want error, got nil")
+	}
+	fields := kv.Fields(err)
+	if got, want := fields.Get("request_id"), "abc123"; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+	if got, want := fields.Get("url"), url; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+	if got, want := len(logged), 1; got != want {
+		t.Fatalf("got %d log lines, want %d", got, want)
+	}
+	if got, want := logged[0], "error: http request failed method=GET"; !strings.HasPrefix(got, want) {
+		t.Errorf("\n got=%v\nwant=%v", got, want)
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	defer func(output func(int, string) error) { kv.LogOutput = output }(kv.LogOutput)
+	kv.LogOutput = func(int, string) error { return nil }
+
+	propagation := &kv.Propagation{Keys: []string{"request_id", "tenant"}}
+	defer func(p *kv.Propagation) { kv.DefaultPropagation = p }(kv.DefaultPropagation)
+	kv.DefaultPropagation = propagation
+
+	// downstream service
+	var gotList kv.List
+	downstream := httptest.NewServer(Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotList = kv.From(r.Context()).List()
+	})))
+	defer downstream.Close()
+
+	// edge service calling the downstream service
+	client := &http.Client{Transport: &Transport{Propagation: propagation}}
+	edge := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := kv.From(r.Context()).With("tenant", "acme", "user", "alice")
+		req, err := http.NewRequestWithContext(ctx, "GET", downstream.URL+"/inner", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}))
+	r := httptest.NewRequest("GET", "/outer", nil)
+	r.Header.Set("X-Request-Id", "abc123")
+	edge.ServeHTTP(httptest.NewRecorder(), r)
+
+	if got, want := gotList.Get("request_id"), "abc123"; got != want {
+		t.Errorf("request_id: got=%v, want=%v", got, want)
+	}
+	if got, want := gotList.Get("tenant"), "acme"; got != want {
+		t.Errorf("tenant: got=%v, want=%v", got, want)
+	}
+	if got := gotList.Get("user"); got != nil {
+		t.Errorf("user: got=%v, want=nil", got)
+	}
+	if got, want := gotList.Get("path"), "/inner"; got != want {
+		t.Errorf("path: got=%v, want=%v", got, want)
+	}
+}
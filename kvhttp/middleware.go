@@ -0,0 +1,189 @@
+package kvhttp
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/jjeffery/kv"
+)
+
+// RequestIDHeader is the HTTP header used to propagate request IDs.
+// It should only be modified during program initialization.
+var RequestIDHeader = "X-Request-Id"
+
+// requestIDKey is the key for the request ID.
+const requestIDKey = kv.Key[string]("request_id")
+
+// maxRequestIDLength is the maximum length of a request ID
+// received in a request header.
+const maxRequestIDLength = 128
+
+// Middleware returns a handler that attaches key/value pairs describing
+// the request to the request context, and then calls next. The key/value
+// pairs are:
+//
+//	method      request method
+//	path        request URL path
+//	remote      remote address of the client
+//	request_id  request ID
+//
+// Any key/value pairs propagated in the request header are attached to the
+// request context first (see kv.Extract).
+//
+// The request ID is taken from the RequestIDHeader request header, or if
+// that is not present, from the propagated key/value pairs. If the
+// request does not have a valid request ID, a new one is generated. The
+// request ID is also set in the response header.
+//
+// When next returns, the middleware logs an access line using kv.Log,
+// with the context's key/value pairs and the response status, the number
+// of bytes in the response body and the duration of the request.
+//
+// The response writer passed to next implements http.Flusher, http.Hijacker
+// and http.Pusher, forwarding to the original response writer, so
+// handlers such as WebSocket upgrades work behind the middleware.
+//
+// If next panics, the panic is recovered and logged as an error, with
+// the context's key/value pairs attached. If the response has not already
+// been started, a "500 Internal Server Error" response is sent. A panic with
+// http.ErrAbortHandler is not recovered.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		c := kv.Extract(r.Context(), r.Header)
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID, _ = requestIDKey.From(c)
+			if !validRequestID(requestID) {
+				requestID = newRequestID()
+			}
+		}
+		ctx := c.Replace(
+			"method", r.Method,
+			"path", r.URL.Path,
+			"remote", r.RemoteAddr,
+			requestIDKey.V(requestID),
+		)
+		r = r.WithContext(ctx)
+		w.Header().Set(RequestIDHeader, requestID)
+
+		rw := &responseWriter{ResponseWriter: w}
+		if err := serve(next, rw, r); err != nil {
+			if kv.Fields(err).Get("panic") == http.ErrAbortHandler {
+				panic(http.ErrAbortHandler)
+			}
+			kv.Log("error:", err)
+			if rw.status == 0 {
+				WriteProblem(rw, err)
+			}
+		}
+		if rw.status == 0 {
+			rw.status = http.StatusOK
+		}
+
+		kv.Log("info: request complete", ctx, kv.With(
+			"status", rw.status,
+			"bytes", rw.bytes,
+			"duration", time.Since(start),
+		))
+	})
+}
+
+// serve calls the handler, and returns an error if the handler panics.
+func serve(h http.Handler, w http.ResponseWriter, r *http.Request) (err error) {
+	defer kv.Recover(&err, r.Context())
+	h.ServeHTTP(w, r)
+	return nil
+}
+
+// validRequestID reports whether id is acceptable as a request ID.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a randomly generated request ID.
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// should not happen: fall back to a time-based request ID
+		return time.Now().UTC().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// responseWriter records the status and number of bytes
+// written in the response.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush implements the http.Flusher interface.
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		f.Flush()
+	}
+}
+
+// Hijack implements the http.Hijacker interface, so that handlers can
+// take over the connection, eg for WebSocket upgrades. It returns
+// http.ErrNotSupported if the original response writer cannot be hijacked.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Push implements the http.Pusher interface. It returns
+// http.ErrNotSupported if the original response writer does not
+// support HTTP/2 server push.
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// Unwrap returns the original response writer, for use
+// by http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
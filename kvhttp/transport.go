@@ -0,0 +1,102 @@
+package kvhttp
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jjeffery/kv"
+)
+
+// Transport is an http.RoundTripper that logs each request using
+// kv.Log, with the key/value pairs attached to the request context and the
+// request method, URL, response status and elapsed time.
+//
+// If the request fails, the error returned is created using kv.Context.Wrap,
+// so that it has the key/value pairs attached to the request context.
+//
+// Transport can forward values attached to the request context as request
+// headers, which is useful for propagating a request ID to other services:
+//
+//	client := &http.Client{
+//		Transport: &kvhttp.Transport{
+//			Headers: map[string]string{"request_id": "X-Request-Id"},
+//		},
+//	}
+type Transport struct {
+	// Base is the transport used to make requests. If nil,
+	// http.DefaultTransport is used.
+	Base http.RoundTripper
+
+	// Headers maps keys to the names of request headers. If the request
+	// context has a value for a key, it is sent in the request header.
+	Headers map[string]string
+
+	// Propagation, if not nil, determines the key/value pairs in the
+	// request context that are propagated in the request header. See
+	// kv.Inject.
+	Propagation *kv.Propagation
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := kv.From(req.Context())
+	req = t.setHeaders(req)
+	url := req.URL.Redacted()
+
+	start := time.Now()
+	resp, err := t.base().RoundTrip(req)
+	elapsed := time.Since(start)
+	if err != nil {
+		kv.Log("error: http request failed", ctx, kv.With(
+			"method", req.Method,
+			"url", url,
+			"elapsed", elapsed,
+			"error", err,
+		))
+		return nil, ctx.Wrap(err, "http request failed").With(
+			"method", req.Method,
+			"url", url,
+		)
+	}
+
+	kv.Log("info: http request", ctx, kv.With(
+		"method", req.Method,
+		"url", url,
+		"status", resp.StatusCode,
+		"elapsed", elapsed,
+	))
+	return resp, nil
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// setHeaders returns the request with any headers set for values
+// in the request context, including any propagated key/value pairs.
+// The request is cloned before any headers are set, as a RoundTripper
+// must not modify the request.
+func (t *Transport) setHeaders(req *http.Request) *http.Request {
+	cloned := false
+	if t.Propagation != nil {
+		req = req.Clone(req.Context())
+		cloned = true
+		t.Propagation.Inject(req.Context(), req.Header)
+	}
+	for key, header := range t.Headers {
+		value, ok := kv.Key[interface{}](key).From(req.Context())
+		if !ok {
+			continue
+		}
+		if !cloned {
+			req = req.Clone(req.Context())
+			cloned = true
+		}
+		req.Header.Set(header, fmt.Sprint(value))
+	}
+	return req
+}
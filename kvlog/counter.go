@@ -0,0 +1,182 @@
+package kvlog
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jjeffery/kv"
+	"github.com/jjeffery/kv/internal/fingerprint"
+)
+
+// ErrorCounter is a Handler that counts error messages, grouping similar
+// messages together by their fingerprint. Messages are similar if they have
+// the same text and the same keys, regardless of their values. (See also
+// kv.Fingerprint, which computes the same fingerprint for an error value).
+//
+// Once started, the error counter periodically logs the most frequent
+// messages and their counts, and then resets the counts.
+//
+//	counter := &kvlog.ErrorCounter{Interval: time.Hour, Top: 5}
+//	counter.Start()
+//	defer counter.Stop()
+//	kvlog.Std.Handle(counter)
+//
+// The exported fields should not be modified once the error counter
+// has been started or registered with a Writer.
+type ErrorCounter struct {
+	Levels   []string      // Levels to count, defaults to "error"
+	Interval time.Duration // Time between logging counts, defaults to one minute
+	Top      int           // Number of messages to log, defaults to 10
+	Logger   *log.Logger   // Logger for counts, defaults to the standard logger
+
+	mutex  sync.Mutex
+	counts map[string]*ErrorCount
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// ErrorCount is the number of times that similar messages were logged.
+type ErrorCount struct {
+	Fingerprint string // Fingerprint of the messages
+	Text        string // Text of the first message
+	Count       int    // Number of messages
+}
+
+// Handles implements the Handler interface.
+func (c *ErrorCounter) Handles(prefix, level string) bool {
+	if len(c.Levels) == 0 {
+		return level == "error"
+	}
+	for _, l := range c.Levels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle implements the Handler interface.
+func (c *ErrorCounter) Handle(msg *Message) {
+	var keys []string
+	for i := 0; i < len(msg.List); i += 2 {
+		keys = append(keys, msg.List[i])
+	}
+	fp := fingerprint.Compute(msg.Text, keys)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[string]*ErrorCount)
+	}
+	count, ok := c.counts[fp]
+	if !ok {
+		count = &ErrorCount{
+			Fingerprint: fp,
+			Text:        msg.Text,
+		}
+		c.counts[fp] = count
+	}
+	count.Count++
+}
+
+// Counts returns the counts of messages since the counts were
+// last reset, most frequent first.
+func (c *ErrorCounter) Counts() []ErrorCount {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.sortedCounts()
+}
+
+// Flush logs the most frequent messages and their counts,
+// and then resets the counts.
+func (c *ErrorCounter) Flush() {
+	c.mutex.Lock()
+	counts := c.sortedCounts()
+	c.counts = nil
+	c.mutex.Unlock()
+
+	top := c.Top
+	if top <= 0 {
+		top = 10
+	}
+	if len(counts) > top {
+		counts = counts[:top]
+	}
+	logger := c.Logger
+	for _, count := range counts {
+		list := kv.List{
+			"count", count.Count,
+			"fingerprint", count.Fingerprint,
+			"text", count.Text,
+		}
+		if logger == nil {
+			log.Println("info: error count", list)
+		} else {
+			logger.Println("info: error count", list)
+		}
+	}
+}
+
+// Start starts a goroutine that calls Flush periodically.
+func (c *ErrorCounter) Start() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.stop != nil {
+		// already started
+		return
+	}
+	interval := c.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+	go c.run(interval, c.stop, c.done)
+}
+
+// Stop stops the goroutine started by Start, and logs any
+// remaining counts.
+func (c *ErrorCounter) Stop() {
+	c.mutex.Lock()
+	stop, done := c.stop, c.done
+	c.stop, c.done = nil, nil
+	c.mutex.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+	c.Flush()
+}
+
+func (c *ErrorCounter) run(interval time.Duration, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.Flush()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// sortedCounts returns the counts, most frequent first. The
+// mutex must be locked.
+func (c *ErrorCounter) sortedCounts() []ErrorCount {
+	counts := make([]ErrorCount, 0, len(c.counts))
+	for _, count := range c.counts {
+		counts = append(counts, *count)
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Fingerprint < counts[j].Fingerprint
+	})
+	return counts
+}
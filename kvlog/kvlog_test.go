@@ -2,13 +2,17 @@ package kvlog
 
 import (
 	"bytes"
+	"io"
 	"io/ioutil"
 	"log"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/jjeffery/kv"
+	"github.com/jjeffery/kv/internal/redact"
 )
 
 func TestWriter(t *testing.T) {
@@ -300,6 +304,133 @@ func TestOutput(t *testing.T) {
 	}
 }
 
+func TestRedact(t *testing.T) {
+	defer redact.Reset()
+	kv.RedactKeys("password")
+
+	var buf bytes.Buffer
+	var handled []string
+	output := NewWriter(&buf)
+	output.Handle(&testHandler{
+		handle: func(msg *Message) {
+			handled = msg.List
+		},
+	})
+	logger := log.New(ioutil.Discard, "", 0)
+	output.Attach(logger)
+
+	logger.Println("login failed user=alice password=p@ssw0rd")
+	if got, want := buf.String(), "login failed user=alice password=\"[REDACTED]\"\n"; got != want {
+		t.Errorf("\n got=%q\nwant=%q", got, want)
+	}
+	if got, want := handled, []string{"user", "alice", "password", "[REDACTED]"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("\n got=%q\nwant=%q", got, want)
+	}
+
+	buf.Reset()
+	logger.Println("login failed", kv.With("password", "p@ssw0rd"))
+	if got, want := buf.String(), "login failed password=\"[REDACTED]\"\n"; got != want {
+		t.Errorf("\n got=%q\nwant=%q", got, want)
+	}
+}
+
+func TestAttachSuppressesLazy(t *testing.T) {
+	defer func(w io.Writer, flags int) {
+		log.SetOutput(w)
+		log.SetFlags(flags)
+		kv.SetLogSuppressed(nil)
+	}(log.Writer(), log.Flags())
+
+	var buf bytes.Buffer
+	output := NewWriter(&buf)
+	output.Suppress("debug")
+	log.SetFlags(0)
+	output.Attach()
+
+	var calls int
+	lazy := kv.Lazy(func() interface{} {
+		calls++
+		return "value"
+	})
+	kv.Log("debug: message", kv.With("a", lazy))
+	kv.Log("info: message", kv.With("a", lazy))
+	if got, want := calls, 1; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+	if got, want := buf.String(), "info: message a=value\n"; got != want {
+		t.Errorf("got=%q, want=%q", got, want)
+	}
+
+	// another writer attached without suppressing debug
+	buf.Reset()
+	NewWriter(&buf).Attach()
+	kv.Log("debug: message", kv.With("a", lazy))
+	if got, want := buf.String(), "debug: message a=value\n"; got != want {
+		t.Errorf("got=%q, want=%q", got, want)
+	}
+
+	// standard logger no longer writes to a kvlog writer
+	buf.Reset()
+	output.Attach()
+	log.SetOutput(&buf)
+	kv.Log("debug: message", kv.With("a", lazy))
+	if got, want := buf.String(), "debug: message a=value\n"; got != want {
+		t.Errorf("got=%q, want=%q", got, want)
+	}
+}
+
+func TestErrorCounter(t *testing.T) {
+	var buf bytes.Buffer
+	counter := &ErrorCounter{Top: 2, Logger: log.New(&buf, "", 0)}
+	output := NewWriter(ioutil.Discard)
+	output.Handle(counter)
+	logger := log.New(ioutil.Discard, "", 0)
+	output.Attach(logger)
+
+	for _, user := range []string{"alice", "bob", "carol"} {
+		logger.Println("error:", kv.NewError("permission denied").With("user", user))
+	}
+	logger.Println("error:", kv.NewError("not found").With("id", 1))
+	logger.Println("error:", kv.NewError("not found").With("id", 2))
+	logger.Println("error: timeout")
+	logger.Println("info: permission denied user=alice")
+
+	counts := counter.Counts()
+	if got, want := len(counts), 3; got != want {
+		t.Fatalf("got=%v, want=%v", got, want)
+	}
+	err := kv.NewError("permission denied").With("user", "dave")
+	if got, want := counts[0], (ErrorCount{Fingerprint: kv.Fingerprint(err), Text: "permission denied", Count: 3}); got != want {
+		t.Errorf("\n got=%+v\nwant=%+v", got, want)
+	}
+	if got, want := counts[1].Count, 2; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+
+	counter.Flush()
+	want := "info: error count count=3 fingerprint=" + counts[0].Fingerprint + " text=\"permission denied\"\n" +
+		"info: error count count=2 fingerprint=" + counts[1].Fingerprint + " text=\"not found\"\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\n got=%q\nwant=%q", got, want)
+	}
+	if got, want := len(counter.Counts()), 0; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+}
+
+func TestErrorCounterStartStop(t *testing.T) {
+	var buf bytes.Buffer
+	counter := &ErrorCounter{Interval: time.Hour, Logger: log.New(&buf, "", 0)}
+	counter.Start()
+	counter.Start()
+	counter.Handle(&Message{Level: "error", Text: "timeout"})
+	counter.Stop()
+	counter.Stop()
+	if got, want := strings.Count(buf.String(), "\n"), 1; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+}
+
 func cloneByteSlice(slice []byte) []byte {
 	if slice == nil {
 		return nil
@@ -10,7 +10,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/jjeffery/kv"
+	"github.com/jjeffery/kv/internal/logfmt"
 	"github.com/jjeffery/kv/internal/parse"
+	"github.com/jjeffery/kv/internal/pool"
+	"github.com/jjeffery/kv/internal/redact"
 )
 
 var (
@@ -218,6 +222,11 @@ func (w *Writer) Handle(h Handler) {
 //
 // This method calls SetOutput for the specified logger
 // (or the standard logger) to set its output writer.
+//
+// When attaching to the standard logger, this method also calls
+// kv.SetLogSuppressed, so that messages logged by kv.Log with a suppressed
+// level are discarded before their key/value pairs are rendered. This
+// stops once the standard logger writes somewhere else.
 func (w *Writer) Attach(logger ...*log.Logger) {
 	if len(logger) == 0 {
 		logger = []*log.Logger{nil}
@@ -226,6 +235,7 @@ func (w *Writer) Attach(logger ...*log.Logger) {
 		lw := newLogWriter(w, l)
 		if l == nil {
 			log.SetOutput(lw)
+			kv.SetLogSuppressed(lw.suppresses)
 		} else {
 			l.SetOutput(lw)
 		}
@@ -239,6 +249,27 @@ func (w *Writer) SetOutput(out io.Writer) {
 	w.mutex.Unlock()
 }
 
+// suppresses reports whether a message starting with text would
+// be suppressed. It returns false if the standard logger no longer
+// writes to w, eg because another writer has been attached.
+func (w *logWriter) suppresses(text string) bool {
+	if log.Writer() != io.Writer(w) {
+		return false
+	}
+	return w.output.suppresses(text)
+}
+
+// suppresses reports whether a message starting with text
+// would be suppressed.
+func (w *Writer) suppresses(text string) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.levels == nil {
+		w.setLevels(Levels)
+	}
+	return w.shouldSuppress([]byte(text))
+}
+
 func (w *Writer) shouldSuppress(msg []byte) bool {
 	for _, levelb := range w.suppress {
 		if bytes.HasPrefix(msg, levelb) {
@@ -301,6 +332,24 @@ func (w *Writer) handler(entry *logEntry) {
 	w.printer.Print(entry)
 }
 
+// redactList replaces any values in the list of key/value pairs that
+// match the redaction rules, so that they are not seen by handlers
+// or printed.
+func redactList(list [][]byte) {
+	if !redact.Enabled() {
+		return
+	}
+	buf := pool.AllocBuffer()
+	defer pool.ReleaseBuffer(buf)
+	for i := 1; i < len(list); i += 2 {
+		buf.Reset()
+		logfmt.WriteValue(buf, list[i])
+		if replacement, ok := redact.Redact(string(list[i-1]), buf.Bytes()); ok {
+			list[i] = []byte(replacement)
+		}
+	}
+}
+
 // logWriter is a writer tailored for a specific logger.
 type logWriter struct {
 	prefixb []byte         // logger prefix bytes
@@ -435,6 +484,7 @@ func (w *logWriter) Write(p []byte) (n int, err error) {
 		level, effect, skip := w.output.getLevel(p)
 		p = p[skip:]
 		msg := parse.Bytes(p)
+		redactList(msg.List)
 		ent := logEntry{
 			Timestamp: now,
 			Prefix:    prefix,
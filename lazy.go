@@ -0,0 +1,30 @@
+package kv
+
+// Valuer is implemented by values that are evaluated only when
+// they are rendered. The Value method is not called when a list,
+// context or error is created, and is only called if the list, context
+// or error is converted to text (or JSON).
+//
+// If the Value method panics, the value is rendered as "PANIC".
+// The value returned by the Value method is rendered as-is: structs
+// and maps are not expanded into key/value pairs.
+type Valuer interface {
+	Value() interface{}
+}
+
+// Lazy is a function that returns a value. It implements the Valuer
+// interface, which means that the function is only called if the value
+// is rendered.
+//
+// Lazy is useful for values that are expensive to calculate. For example
+// in the following, the function is not called if the message is suppressed.
+//
+//	kv.Log("debug: request received", kv.With("payload", kv.Lazy(func() interface{} {
+//	    return dump(payload)
+//	})))
+type Lazy func() interface{}
+
+// Value implements the Valuer interface.
+func (f Lazy) Value() interface{} {
+	return f()
+}
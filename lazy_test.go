@@ -0,0 +1,100 @@
+package kv
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLazy(t *testing.T) {
+	var calls int
+	lazy := Lazy(func() interface{} {
+		calls++
+		return "lazy value"
+	})
+
+	list := With("a", 1, "b", lazy)
+	err := NewError("message").With("b", lazy)
+	ctx := From(nil).With("b", lazy)
+	_ = Wrap(err, "wrapped")
+	if calls != 0 {
+		t.Fatalf("got=%d, want=0", calls)
+	}
+
+	if got, want := list.String(), `a=1 b="lazy value"`; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+	if got, want := calls, 1; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+
+	calls = 0
+	if got, want := err.Error(), `message b="lazy value"`; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+	if got, want := calls, 1; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+
+	calls = 0
+	if got, want := From(ctx).NewError("message").Error(), `message b="lazy value"`; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+	if got, want := calls, 1; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+
+	b, _ := json.Marshal(list)
+	if got, want := string(b), `{"a":1,"b":"lazy value"}`; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+
+	panicking := Lazy(func() interface{} {
+		panic("oops")
+	})
+	if got, want := With("a", panicking).String(), "a=PANIC"; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+	if got, want := NewError("message").With("a", panicking).Error(), "message a=PANIC"; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+
+	nested := Lazy(func() interface{} {
+		return Lazy(func() interface{} {
+			return 42
+		})
+	})
+	if got, want := With("a", nested).String(), "a=42"; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+}
+
+func TestLogSuppressed(t *testing.T) {
+	var output []string
+	defer func(logOutput func(int, string) error) {
+		LogOutput = logOutput
+		SetLogSuppressed(nil)
+	}(LogOutput)
+	LogOutput = func(calldepth int, s string) error {
+		output = append(output, strings.TrimSpace(s))
+		return nil
+	}
+	SetLogSuppressed(func(text string) bool {
+		return strings.HasPrefix(text, "debug:")
+	})
+
+	var calls int
+	lazy := Lazy(func() interface{} {
+		calls++
+		return 1
+	})
+
+	Log("debug: message", With("a", lazy))
+	Log("info: message", With("a", lazy))
+	if got, want := strings.Join(output, "\n"), "info: message a=1"; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+	if got, want := calls, 1; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+}
@@ -3,7 +3,6 @@ package kv
 import (
 	"bytes"
 	"context"
-	"fmt"
 
 	"github.com/jjeffery/kv/internal/logfmt"
 	"github.com/jjeffery/kv/internal/parse"
@@ -13,6 +12,19 @@ import (
 // List is a slice of alternating keys and values.
 type List []interface{}
 
+// Pair is a single key/value pair.
+type Pair struct {
+	Key   string
+	Value interface{}
+}
+
+// Keyvals returns the key/value pair as a slice of length two,
+// so that a Pair is treated as a single key/value pair wherever
+// key/value pairs are accepted.
+func (p Pair) Keyvals() []interface{} {
+	return []interface{}{p.Key, p.Value}
+}
+
 // Parse parses the input and reports the message text,
 // and the list of key/value pairs.
 //
@@ -37,18 +49,85 @@ func With(keyvals ...interface{}) List {
 	return List(keyvals)
 }
 
+// Group returns a list populated with keyvals as the key/value pairs,
+// where each key is prefixed with the group name and a period. For example
+//
+//	kv.Group("http", kv.With("method", "GET", "status", 200))
+//
+// renders as
+//
+//	http.method=GET http.status=200
+//
+// Groups can be nested, in which case the prefixes are combined.
+func Group(name string, keyvals ...interface{}) List {
+	return With(keyvals...).Group(name)
+}
+
 // From returns a new context with key/value pairs copied both from
 // the list and the context.
 func (l List) From(ctx context.Context) Context {
-	ctx = newContext(ctx, l)
+	ctx = newContext(ctx, nil, l)
 	return &contextT{ctx: ctx}
 }
 
+// Get returns the value associated with key, or nil if the
+// list does not contain the key. See also Lookup.
+func (l List) Get(key string) interface{} {
+	value, _ := l.Lookup(key)
+	return value
+}
+
+// Group returns a new list with the same key/value pairs as
+// the list (l), except that each key is prefixed with the group name
+// and a period. The original list is not modified. If name is blank,
+// the keys are not prefixed.
+func (l List) Group(name string) List {
+	fl := flattenFix(l)
+	list := make(List, len(fl))
+	copy(list, fl)
+	if name != "" {
+		prefix := name + keySeparator
+		for i := 0; i < len(list); i += 2 {
+			list[i] = prefix + list[i].(string)
+		}
+	}
+	return list
+}
+
+// Keys returns the keys in the list in the order that they first
+// appear. A key that appears more than once is only returned once.
+func (l List) Keys() []string {
+	var keys []string
+	seen := make(map[string]struct{})
+	l.Range(func(key string, value interface{}) bool {
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			keys = append(keys, key)
+		}
+		return true
+	})
+	return keys
+}
+
 // Keyvals returns the list cast as []interface{}.
 func (l List) Keyvals() []interface{} {
 	return []interface{}(l)
 }
 
+// Lookup returns the value associated with key, and reports whether
+// the key was found. If the key appears more than once in the list,
+// the first value is returned.
+func (l List) Lookup(key string) (value interface{}, ok bool) {
+	l.Range(func(k string, v interface{}) bool {
+		if k == key {
+			value, ok = v, true
+			return false
+		}
+		return true
+	})
+	return value, ok
+}
+
 // MarshalText implements the TextMarshaler interface.
 func (l List) MarshalText() (text []byte, err error) {
 	var buf bytes.Buffer
@@ -64,6 +143,36 @@ func (l List) NewError(text string) Error {
 	return e
 }
 
+// Pairs returns the key/value pairs in the list, in order.
+func (l List) Pairs() []Pair {
+	fl := flattenFix(l)
+	if len(fl) == 0 {
+		return nil
+	}
+	pairs := make([]Pair, 0, len(fl)/2)
+	for i := 0; i < len(fl); i += 2 {
+		key, _ := fl[i].(string)
+		pairs = append(pairs, Pair{Key: key, Value: unwrapOpaque(fl[i+1])})
+	}
+	return pairs
+}
+
+// Range calls fn for each key/value pair in the list, in order.
+// If fn returns false, Range stops the iteration.
+//
+// Range operates on the list after it has been flattened and fixed,
+// so key/value pairs in nested lists are included, and every key
+// is a string.
+func (l List) Range(fn func(key string, value interface{}) bool) {
+	fl := flattenFix(l)
+	for i := 0; i < len(fl); i += 2 {
+		key, _ := fl[i].(string)
+		if !fn(key, unwrapOpaque(fl[i+1])) {
+			return
+		}
+	}
+}
+
 // String returns a string representation of the key/value pairs in
 // logfmt format: "key1=value1 key2=value2  ...".
 func (l List) String() string {
@@ -84,7 +193,7 @@ func (l *List) UnmarshalText(text []byte) error {
 	list := make(List, 0, capacity)
 
 	if len(m.Text) > 0 {
-		list = append(list, "msg", string(m.Text))
+		list = append(list, msgKey(), string(m.Text))
 	}
 	for _, v := range m.List {
 		list = append(list, string(v))
@@ -96,7 +205,13 @@ func (l *List) UnmarshalText(text []byte) error {
 // With returns a new list with keyvals appended. The original
 // list (l) is not modified.
 func (l List) With(keyvals ...interface{}) List {
-	keyvals = flattenFix(keyvals)
+	return l.withPolicy(DefaultKeyPolicy, keyvals)
+}
+
+// withPolicy returns a new list with keyvals appended, with any
+// missing keys named according to policy.
+func (l List) withPolicy(policy *KeyPolicy, keyvals []interface{}) List {
+	keyvals = flattenFixPolicy(keyvals, policy)
 	list := l.clone(len(l) + len(keyvals))
 	list = append(list, keyvals...)
 	return list
@@ -116,6 +231,34 @@ func (l List) Log(args ...interface{}) {
 	logHelper(2, l, args...)
 }
 
+// without returns the list without any key/value pairs whose keys
+// are in removed. If no key/value pairs are removed, the list is returned
+// unchanged.
+func (l List) without(removed map[string]struct{}) List {
+	if len(removed) == 0 {
+		return l
+	}
+	var list List
+	fl := flattenFix(l)
+	for i := 0; i < len(fl); i += 2 {
+		key, _ := fl[i].(string)
+		if _, ok := removed[key]; ok {
+			if list == nil {
+				list = make(List, i, len(fl))
+				copy(list, fl[:i])
+			}
+			continue
+		}
+		if list != nil {
+			list = append(list, fl[i], fl[i+1])
+		}
+	}
+	if list == nil {
+		return l
+	}
+	return list
+}
+
 func (l List) clone(capacity int) List {
 	length := len(l)
 	if capacity < length {
@@ -137,51 +280,3 @@ func (l List) writeToBuffer(buf logfmt.Writer) {
 		logfmt.WriteKeyValue(buf, k, v)
 	}
 }
-
-func dedup(lists ...List) List {
-	var (
-		totalLen int
-	)
-	for _, list := range lists {
-		totalLen += len(list)
-	}
-	if totalLen == 0 {
-		return nil
-	}
-	result := make(List, 0, totalLen)
-	m := make(map[string]map[string]struct{})
-	buf := pool.AllocBuffer()
-	defer pool.ReleaseBuffer(buf)
-
-	valueString := func(val interface{}) string {
-		buf.Reset()
-		logfmt.WriteValue(buf, val)
-		return buf.String()
-	}
-
-	for _, list := range lists {
-		contents := flattenFix(list)
-		for i := 0; i < len(contents); i += 2 {
-			key, ok := contents[i].(string)
-			if !ok {
-				// shouldn't happen, unless a different type is
-				// returned for missing keys, which might happen
-				// if the flatten/fix function is modified in future
-				key = fmt.Sprint(key)
-			}
-			val := contents[i+1]
-			valstr := valueString(val)
-			valstrs, found := m[key]
-			if !found {
-				valstrs = make(map[string]struct{})
-				m[key] = valstrs
-			}
-			if _, ok := valstrs[valstr]; !ok {
-				result = append(result, key, val)
-				valstrs[valstr] = struct{}{}
-			}
-		}
-	}
-
-	return result
-}
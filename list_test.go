@@ -1,6 +1,8 @@
 package kv
 
 import (
+	"context"
+	"fmt"
 	"reflect"
 	"testing"
 
@@ -92,6 +94,137 @@ func TestListClone(t *testing.T) {
 	}
 }
 
+func TestListLookup(t *testing.T) {
+	list := List{"a", 1, List{"b", "two", "a", 3}, "c", nil, 4}
+	tests := []struct {
+		key   string
+		value interface{}
+		ok    bool
+	}{
+		{key: "a", value: 1, ok: true},
+		{key: "b", value: "two", ok: true},
+		{key: "c", value: nil, ok: true},
+		{key: "_p1", value: 4, ok: true},
+		{key: "d", value: nil, ok: false},
+	}
+	for tn, tt := range tests {
+		value, ok := list.Lookup(tt.key)
+		if got, want := ok, tt.ok; got != want {
+			t.Errorf("%d: got=%v, want=%v", tn, got, want)
+			continue
+		}
+		if got, want := value, tt.value; got != want {
+			t.Errorf("%d: got=%v, want=%v", tn, got, want)
+			continue
+		}
+		if got, want := list.Get(tt.key), tt.value; got != want {
+			t.Errorf("%d: got=%v, want=%v", tn, got, want)
+			continue
+		}
+	}
+}
+
+func TestListIterate(t *testing.T) {
+	list := List{"a", 1, testKeyvalser{}, "a", 2}
+
+	if got, want := list.Keys(), []string{"a", "1", "3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("keys:\n got=%v\nwant=%v", got, want)
+	}
+
+	wantPairs := []Pair{
+		{Key: "a", Value: 1},
+		{Key: "1", Value: "2"},
+		{Key: "3", Value: "4"},
+		{Key: "a", Value: 2},
+	}
+	if got, want := list.Pairs(), wantPairs; !reflect.DeepEqual(got, want) {
+		t.Errorf("pairs:\n got=%v\nwant=%v", got, want)
+	}
+
+	var pairs []Pair
+	list.Range(func(key string, value interface{}) bool {
+		pairs = append(pairs, Pair{Key: key, Value: value})
+		return len(pairs) < 2
+	})
+	if got, want := pairs, wantPairs[:2]; !reflect.DeepEqual(got, want) {
+		t.Errorf("range:\n got=%v\nwant=%v", got, want)
+	}
+
+	if got := List(nil).Pairs(); got != nil {
+		t.Errorf("got=%v, want nil", got)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	tests := []struct {
+		fn   func() interface{}
+		want string
+	}{
+		{
+			fn: func() interface{} {
+				return Group("http", With("method", "GET", "status", 200))
+			},
+			want: "http.method=GET http.status=200",
+		},
+		{
+			fn: func() interface{} {
+				return Group("http", "method", "GET", "status", 200)
+			},
+			want: "http.method=GET http.status=200",
+		},
+		{
+			fn: func() interface{} {
+				return Group("a", "x", 1, Group("b", "y", 2, Group("c", "z", 3)))
+			},
+			want: "a.x=1 a.b.y=2 a.b.c.z=3",
+		},
+		{
+			fn: func() interface{} {
+				return With("status", 404).With(Group("http", "status", 200))
+			},
+			want: "status=404 http.status=200",
+		},
+		{
+			fn: func() interface{} {
+				return With("a", 1).Group("")
+			},
+			want: "a=1",
+		},
+		{
+			fn: func() interface{} {
+				ctx := From(context.Background()).With(Group("http", "method", "GET"))
+				return From(ctx).With("status", 200)
+			},
+			want: "status=200 http.method=GET",
+		},
+		{
+			fn: func() interface{} {
+				return NewError("not found").With(Group("http", "status", 404), "status", 1)
+			},
+			want: "not found http.status=404 status=1",
+		},
+		{
+			fn: func() interface{} {
+				return dedup(List{"status", 1, "http.status", 1}, Group("http", "status", 1))
+			},
+			want: "status=1 http.status=1",
+		},
+	}
+	for tn, tt := range tests {
+		if got, want := fmt.Sprint(tt.fn()), tt.want; got != want {
+			t.Errorf("%d:\n got=%v\nwant=%v", tn, got, want)
+		}
+	}
+
+	list := With("a", 1)
+	if got, want := list.Group("g"), (List{"g.a", 1}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+	if got, want := list, (List{"a", 1}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+}
+
 func BenchmarkList1(b *testing.B) {
 	benchmarkListString(With("a", 1), b)
 }
@@ -4,17 +4,39 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
+	"sync/atomic"
 )
 
-var (
-	// LogOutput is the function called to log a message.
-	// The default value calls the Output function for the
-	// standard logger in the Go standard liberary.
-	LogOutput = log.Output
-)
+// LogOutput is the function called to log a message.
+// The default value calls the Output function for the
+// standard logger in the Go standard liberary.
+var LogOutput = log.Output
+
+// logSuppressed holds the function set by SetLogSuppressed.
+var logSuppressed atomic.Value // func(text string) bool
+
+// SetLogSuppressed sets a function that is called with the message text
+// before a message is logged. If it returns true, the message is discarded
+// before any key/value pairs are rendered, so lazy values (see Lazy)
+// are not evaluated. The message text passed does not include
+// any key/value pairs. Calling SetLogSuppressed with nil removes
+// the function.
+//
+// The kvlog package calls SetLogSuppressed when it is attached to the
+// standard logger, so that messages with suppressed levels are
+// discarded early. It is safe to call SetLogSuppressed while other
+// goroutines are logging.
+func SetLogSuppressed(fn func(text string) bool) {
+	logSuppressed.Store(fn)
+}
 
 // Log is used to log a message. By default the message is logged
 // using the standard logger in the Go "log" package.
+//
+// The arguments can include lists and contexts, whose key/value pairs
+// are appended to the message, and a DupPolicy, which determines how
+// any duplicate keys are handled.
 func Log(args ...interface{}) {
 	logHelper(2, nil, args...)
 }
@@ -23,6 +45,7 @@ func logHelper(calldepth int, list List, args ...interface{}) {
 	var ctx context.Context
 	var lists []List
 	var others []interface{}
+	var dup DupPolicy
 
 	if list != nil {
 		lists = append(lists, list)
@@ -34,19 +57,37 @@ func logHelper(calldepth int, list List, args ...interface{}) {
 			ctx = From(v)
 		case List:
 			lists = append(lists, v)
+		case PolicyList:
+			lists = append(lists, v.List)
+		case Pair:
+			lists = append(lists, With(v))
+		case DupPolicy:
+			dup = v
 		default:
 			others = append(others, arg)
 		}
 	}
 
 	if ctx != nil {
-		keyvals := fromContext(ctx)
-		if len(keyvals) > 0 {
-			lists = append(lists, List(keyvals))
+		ctxlists, ctxdup := listsFromContext(ctx)
+		lists = append(lists, ctxlists...)
+		if dup == 0 {
+			dup = ctxdup
 		}
 	}
 
-	others = append(others, dedup(lists...))
-	s := fmt.Sprintln(others...)
+	// the message text is only rendered once
+	text := fmt.Sprintln(others...)
+	if suppressed, _ := logSuppressed.Load().(func(string) bool); suppressed != nil && suppressed(text) {
+		return
+	}
+
+	fields := dedupPolicy(dup, lists...)
+	var s string
+	if len(others) == 0 {
+		s = fmt.Sprintln(fields)
+	} else {
+		s = strings.TrimSuffix(text, "\n") + " " + fmt.Sprint(fields) + "\n"
+	}
 	LogOutput(calldepth+1, s)
 }
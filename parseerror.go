@@ -0,0 +1,79 @@
+package kv
+
+import (
+	"strings"
+)
+
+// ParseError parses an error message rendered by an Error, and returns
+// an error that is equivalent to the original error. It is useful for
+// errors that have crossed a process boundary, such as an error message
+// read from the standard error of a subprocess.
+//
+// The message text is split into segments separated by a colon and a space
+// (": "), and each segment becomes an error that wraps the error for
+// the following segment. The key/value pairs, including the error code, are
+// attached to the innermost error, so that
+//
+//	kv.ParseError(`cannot open file: permission denied code=unauthorized user=alice`)
+//
+// returns an error equivalent to
+//
+//	err := kv.NewError("permission denied").WithCode(kv.Unauthorized).With("user", "alice")
+//	err = kv.Wrap(err, "cannot open file")
+//
+// All values in the key/value pairs are strings.
+//
+// The errors returned by ParseError match other errors with errors.Is
+// if they have the same code, or, if the other error does not have a code,
+// the same message text. For example, ParseError("read failed: EOF") matches
+// io.EOF.
+//
+// ParseError returns nil if s is blank.
+func ParseError(s string) Error {
+	textb, list := Parse([]byte(s))
+	text := strings.TrimSpace(string(textb))
+	if text == "" {
+		text, list = removeMsg(list)
+	}
+	if text == "" && len(list) == 0 {
+		return nil
+	}
+
+	var code string
+	for i := 0; i < len(list); i += 2 {
+		if key, _ := list[i].(string); key == codeKey {
+			code, _ = list[i+1].(string)
+			break
+		}
+	}
+	if code != "" {
+		// remove any code key/value pairs
+		list = withCode(code, list)[2:]
+	}
+
+	segments := strings.Split(text, ": ")
+	last := len(segments) - 1
+	e := &errorT{
+		text:   segments[last],
+		list:   list,
+		code:   code,
+		parsed: true,
+	}
+	for i := last - 1; i >= 0; i-- {
+		e = &errorT{
+			text:   segments[i],
+			err:    causer(e),
+			parsed: true,
+		}
+	}
+	return causer(e)
+}
+
+// parsedIs reports whether an error returned by ParseError
+// matches target.
+func (e *errorT) parsedIs(target error) bool {
+	if code := Code(target); code != "" {
+		return code == e.code
+	}
+	return Message(e) == Message(target)
+}
@@ -0,0 +1,110 @@
+package kv
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		text     string
+		wantText string
+		wantMsg  string
+		wantList List
+		wantCode string
+		wantLen  int
+	}{
+		{
+			text:     "message text",
+			wantText: "message text",
+			wantMsg:  "message text",
+			wantLen:  1,
+		},
+		{
+			text:     `cannot open file: permission denied code=unauthorized file="/etc/passwd" user=alice`,
+			wantText: `cannot open file: permission denied code=unauthorized file="/etc/passwd" user=alice`,
+			wantMsg:  "cannot open file: permission denied",
+			wantList: List{"code", "unauthorized", "file", "/etc/passwd", "user", "alice"},
+			wantCode: Unauthorized,
+			wantLen:  2,
+		},
+		{
+			text:     "third: second: first a=1 code=not_found",
+			wantText: "third: second: first code=not_found a=1",
+			wantMsg:  "third: second: first",
+			wantList: List{"code", "not_found", "a", "1"},
+			wantCode: NotFound,
+			wantLen:  3,
+		},
+		{
+			text:     `msg="message text" a=1`,
+			wantText: "message text a=1",
+			wantMsg:  "message text",
+			wantList: List{"a", "1"},
+			wantLen:  1,
+		},
+	}
+	for tn, tt := range tests {
+		err := ParseError(tt.text)
+		if got, want := err.Error(), tt.wantText; got != want {
+			t.Errorf("%d: text:\n got=%v\nwant=%v", tn, got, want)
+		}
+		if got, want := Message(err), tt.wantMsg; got != want {
+			t.Errorf("%d: msg:\n got=%v\nwant=%v", tn, got, want)
+		}
+		if got, want := Fields(err), tt.wantList; !reflect.DeepEqual(got, want) {
+			t.Errorf("%d: fields:\n got=%v\nwant=%v", tn, got, want)
+		}
+		if got, want := Code(err), tt.wantCode; got != want {
+			t.Errorf("%d: code: got=%v, want=%v", tn, got, want)
+		}
+		var n int
+		for e := error(err); e != nil; e = errors.Unwrap(e) {
+			n++
+		}
+		if got, want := n, tt.wantLen; got != want {
+			t.Errorf("%d: chain length: got=%v, want=%v", tn, got, want)
+		}
+	}
+	if err := ParseError("  "); err != nil {
+		t.Errorf("got=%v, want=nil", err)
+	}
+}
+
+func TestParseErrorIs(t *testing.T) {
+	errNotFound := NewError("not found").WithCode(NotFound)
+	tests := []struct {
+		text   string
+		target error
+		want   bool
+	}{
+		{
+			text:   "read failed: EOF",
+			target: io.EOF,
+			want:   true,
+		},
+		{
+			text:   "read failed: unexpected EOF",
+			target: io.EOF,
+			want:   false,
+		},
+		{
+			text:   "cannot find user: no such user code=not_found user=alice",
+			target: errNotFound,
+			want:   true,
+		},
+		{
+			text:   "cannot find user: not found code=invalid",
+			target: errNotFound,
+			want:   false,
+		},
+	}
+	for tn, tt := range tests {
+		err := Wrap(ParseError(tt.text), "subprocess failed")
+		if got, want := errors.Is(err, tt.target), tt.want; got != want {
+			t.Errorf("%d: got=%v, want=%v", tn, got, want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package kv
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Propagation determines how key/value pairs attached to a context are
+// propagated to other processes in HTTP headers. The key/value pairs are
+// sent in a header using the format of the W3C Baggage specification:
+//
+//	baggage: request_id=abc123,tenant=acme
+//
+// Only the keys in the allow-list are propagated, so that key/value pairs
+// that are private to a process are not sent, and so that a client cannot
+// attach arbitrary key/value pairs to the context of a server.
+type Propagation struct {
+	// Keys is the allow-list of keys that are propagated.
+	Keys []string
+
+	// MaxBytes is the maximum size of the header value. Key/value pairs
+	// that do not fit are not propagated. If MaxBytes is zero, the limit
+	// is 8192 bytes, which is the limit in the W3C Baggage specification.
+	MaxBytes int
+
+	// Header is the name of the HTTP header. If Header is blank,
+	// the "baggage" header is used.
+	Header string
+}
+
+// DefaultPropagation is used by the Inject and Extract functions.
+// It should only be modified during program initialization.
+var DefaultPropagation = &Propagation{
+	Keys:     []string{"request_id"},
+	MaxBytes: 8192,
+	Header:   "baggage",
+}
+
+// Inject adds the key/value pairs attached to ctx to the HTTP header,
+// according to DefaultPropagation.
+func Inject(ctx context.Context, header http.Header) {
+	DefaultPropagation.Inject(ctx, header)
+}
+
+// Extract returns a context based on ctx, with any key/value pairs from
+// the HTTP header attached, according to DefaultPropagation.
+func Extract(ctx context.Context, header http.Header) Context {
+	return DefaultPropagation.Extract(ctx, header)
+}
+
+// Inject adds the key/value pairs attached to ctx to the HTTP header,
+// for each key in the allow-list. Any existing header value is kept, except
+// for members with keys in the allow-list, which are replaced.
+func (p *Propagation) Inject(ctx context.Context, header http.Header) {
+	name := p.headerName()
+	var members []string
+	for _, member := range splitBaggage(header.Values(name)) {
+		if key, _, ok := parseBaggageMember(member); ok && p.allows(key) {
+			continue
+		}
+		members = append(members, member)
+	}
+	size := len(strings.Join(members, ","))
+	for _, key := range p.Keys {
+		value, ok := Key[interface{}](key).From(ctx)
+		if !ok {
+			continue
+		}
+		member := url.PathEscape(key) + "=" + url.PathEscape(fmt.Sprint(value))
+		newSize := size + len(member)
+		if len(members) > 0 {
+			newSize++ // separator
+		}
+		if newSize > p.maxBytes() {
+			continue
+		}
+		members = append(members, member)
+		size = newSize
+	}
+	if len(members) == 0 {
+		header.Del(name)
+		return
+	}
+	header.Set(name, strings.Join(members, ","))
+}
+
+// Extract returns a context based on ctx, with the key/value pairs from
+// the HTTP header attached, for each key in the allow-list. The values are
+// attached as strings, in place of any existing values for the same keys.
+// Members beyond the size limit are ignored.
+func (p *Propagation) Extract(ctx context.Context, header http.Header) Context {
+	var (
+		keyvals []interface{}
+		size    int
+	)
+	for i, member := range splitBaggage(header.Values(p.headerName())) {
+		if i > 0 {
+			size++ // separator
+		}
+		size += len(member)
+		if size > p.maxBytes() {
+			break
+		}
+		if key, value, ok := parseBaggageMember(member); ok && p.allows(key) {
+			keyvals = append(keyvals, key, value)
+		}
+	}
+	c := From(ctx)
+	if len(keyvals) == 0 {
+		return c
+	}
+	return From(c.Replace(keyvals...))
+}
+
+func (p *Propagation) allows(key string) bool {
+	for _, k := range p.Keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Propagation) headerName() string {
+	if p.Header == "" {
+		return "baggage"
+	}
+	return p.Header
+}
+
+func (p *Propagation) maxBytes() int {
+	if p.MaxBytes <= 0 {
+		return 8192
+	}
+	return p.MaxBytes
+}
+
+// splitBaggage splits the header values into list members.
+func splitBaggage(values []string) []string {
+	var members []string
+	for _, value := range values {
+		for _, member := range strings.Split(value, ",") {
+			if member = strings.TrimSpace(member); member != "" {
+				members = append(members, member)
+			}
+		}
+	}
+	return members
+}
+
+// parseBaggageMember returns the key and value of a list member.
+// Any properties following the value are ignored.
+func parseBaggageMember(member string) (key string, value string, ok bool) {
+	if i := strings.IndexByte(member, ';'); i >= 0 {
+		member = member[:i]
+	}
+	i := strings.IndexByte(member, '=')
+	if i < 0 {
+		return "", "", false
+	}
+	key, err := url.PathUnescape(strings.TrimSpace(member[:i]))
+	if err != nil || key == "" {
+		return "", "", false
+	}
+	value, err = url.PathUnescape(strings.TrimSpace(member[i+1:]))
+	if err != nil {
+		return "", "", false
+	}
+	return key, value, true
+}
@@ -0,0 +1,119 @@
+package kv
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestInject(t *testing.T) {
+	p := &Propagation{Keys: []string{"request_id", "tenant", "note"}}
+	tests := []struct {
+		ctx      context.Context
+		existing string
+		want     string
+	}{
+		{
+			ctx:  context.Background(),
+			want: "",
+		},
+		{
+			ctx:  From(context.Background()).With("request_id", "abc123", "user", "alice", "tenant", "acme"),
+			want: "request_id=abc123,tenant=acme",
+		},
+		{
+			ctx:  From(context.Background()).With("note", "a b,c;d=e", "request_id", 42),
+			want: "request_id=42,note=a%20b%2Cc%3Bd=e",
+		},
+		{
+			ctx:      From(context.Background()).With("request_id", "abc123"),
+			existing: "vendor=x;prop=1, request_id=old",
+			want:     "vendor=x;prop=1,request_id=abc123",
+		},
+		{
+			ctx:      context.Background(),
+			existing: "request_id=old",
+			want:     "",
+		},
+		{
+			ctx:  From(context.Background()).With("request_id", Lazy(func() interface{} { return "lazy" })),
+			want: "request_id=lazy",
+		},
+		{
+			ctx:  From(context.Background()).With("request_id", Lazy(func() interface{} { panic("boom") })),
+			want: "request_id=PANIC",
+		},
+	}
+	for tn, tt := range tests {
+		header := make(http.Header)
+		if tt.existing != "" {
+			header.Set("Baggage", tt.existing)
+		}
+		p.Inject(tt.ctx, header)
+		if got, want := header.Get("Baggage"), tt.want; got != want {
+			t.Errorf("%d:\n got=%v\nwant=%v", tn, got, want)
+		}
+	}
+}
+
+func TestInjectMaxBytes(t *testing.T) {
+	p := &Propagation{Keys: []string{"a", "b", "c"}, MaxBytes: 12, Header: "X-Baggage"}
+	ctx := From(context.Background()).With("a", "1", "b", "too long", "c", "3")
+	header := make(http.Header)
+	p.Inject(ctx, header)
+	if got, want := header.Get("X-Baggage"), "a=1,c=3"; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	p := &Propagation{Keys: []string{"request_id", "tenant", "note"}}
+	tests := []struct {
+		header []string
+		want   string
+	}{
+		{
+			header: nil,
+			want:   "a=1",
+		},
+		{
+			header: []string{"request_id=abc123,tenant=acme"},
+			want:   "request_id=abc123 tenant=acme a=1",
+		},
+		{
+			header: []string{"request_id=abc123;prop=1", " other=x , tenant = acme "},
+			want:   "request_id=abc123 tenant=acme a=1",
+		},
+		{
+			header: []string{"note=a%20b%2Cc%3Bd=e,bad%zz=1,noequals"},
+			want:   `note="a b,c;d=e" a=1`,
+		},
+		{
+			header: []string{"request_id=" + strings.Repeat("x", 9000) + ",tenant=acme"},
+			want:   "a=1",
+		},
+	}
+	for tn, tt := range tests {
+		header := make(http.Header)
+		for _, v := range tt.header {
+			header.Add("Baggage", v)
+		}
+		ctx := p.Extract(From(context.Background()).With("a", 1), header)
+		if got, want := ctx.List().String(), tt.want; got != want {
+			t.Errorf("%d:\n got=%v\nwant=%v", tn, got, want)
+		}
+	}
+}
+
+func TestInjectExtract(t *testing.T) {
+	ctx := From(context.Background()).With("request_id", "abc 123", "user", "alice")
+	header := make(http.Header)
+	Inject(ctx, header)
+
+	ctx2 := Extract(context.Background(), header).With("request_id", "other")
+	ctx3 := Extract(ctx2, header)
+	if got, want := From(ctx3).List().String(), `request_id="abc 123"`; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+}
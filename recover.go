@@ -0,0 +1,89 @@
+package kv
+
+import (
+	"context"
+	"runtime"
+	"strings"
+)
+
+// Repanic determines whether Recover and Safe panic again after
+// converting a panic into an error. This is useful during development
+// and testing, where a panic should not go unnoticed. The error is
+// stored before panicking again, so it is available to any deferred
+// functions that run afterwards.
+//
+// Repanic should only be modified during program initialization.
+var Repanic = false
+
+// panicKey is the key for the value passed to panic.
+const panicKey = "panic"
+
+// Recover converts a panic into an error, and stores it in *errp.
+// It must be called directly by a deferred function call:
+//
+//	func doWork(ctx context.Context) (err error) {
+//		defer kv.Recover(&err, ctx)
+//		// ... work that might panic
+//	}
+//
+// The error has the message "panic", and the value passed to panic as
+// the "panic" key/value pair. A stack trace of the panic is always captured,
+// regardless of the value of CaptureStacks. If a context is passed, any
+// key/value pairs attached to it are attached to the error.
+//
+// If there is no panic, Recover does nothing.
+func Recover(errp *error, ctx ...context.Context) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	err := newPanicError(firstContext(ctx), r)
+	if errp != nil {
+		*errp = err
+	}
+	if Repanic {
+		panic(r)
+	}
+}
+
+// Safe calls fn, and returns the error that it returns. If fn panics,
+// the panic is converted into an error in the same way as Recover.
+func Safe(fn func() error, ctx ...context.Context) (err error) {
+	// Recover calls recover, so it must be deferred directly
+	defer Recover(&err, ctx...)
+	return fn()
+}
+
+// newPanicError returns an error for the value passed to panic.
+// It must be called by Recover.
+func newPanicError(ctx context.Context, r interface{}) Error {
+	e := newError(ctx, nil, "panic")
+	// the panic value is never expanded, even if it is a struct or map
+	e.list = List{panicKey, opaque(r)}
+	// skip runtime.Callers, callers, newPanicError and Recover,
+	// and then the runtime functions that handle the panic
+	e.stack = trimRuntime(callers(4))
+	return e
+}
+
+// firstContext returns the first non-nil context, or nil.
+func firstContext(ctxs []context.Context) context.Context {
+	for _, ctx := range ctxs {
+		if ctx != nil {
+			return ctx
+		}
+	}
+	return nil
+}
+
+// trimRuntime removes the leading frames that are in the runtime package.
+func trimRuntime(pcs []uintptr) []uintptr {
+	for len(pcs) > 0 {
+		fn := runtime.FuncForPC(Frame(pcs[0]).pc())
+		if fn == nil || !strings.HasPrefix(fn.Name(), "runtime.") {
+			break
+		}
+		pcs = pcs[1:]
+	}
+	return pcs
+}
@@ -0,0 +1,112 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestRecover(t *testing.T) {
+	type panicStruct struct{ A, B int }
+	ctx := From(context.Background()).With("request", 42)
+
+	tests := []struct {
+		fn   func() error
+		want string
+	}{
+		{
+			fn: func() (err error) {
+				defer Recover(&err)
+				panic("boom")
+			},
+			want: "panic panic=boom",
+		},
+		{
+			fn: func() (err error) {
+				defer Recover(&err, ctx)
+				panic(errors.New("boom"))
+			},
+			want: "panic panic=boom request=42",
+		},
+		{
+			fn: func() (err error) {
+				defer Recover(&err)
+				panic(panicStruct{1, 2})
+			},
+			want: "panic panic=\"{1 2}\"",
+		},
+		{
+			fn: func() (err error) {
+				defer Recover(&err, ctx)
+				return NewError("no panic")
+			},
+			want: "no panic",
+		},
+		{
+			fn: func() error {
+				return Safe(func() error {
+					var m map[string]int
+					m["a"] = 1
+					return nil
+				}, ctx)
+			},
+			want: "panic panic=\"assignment to entry in nil map\" request=42",
+		},
+		{
+			fn: func() error {
+				return Safe(func() error {
+					return NewError("no panic")
+				})
+			},
+			want: "no panic",
+		},
+	}
+	for tn, tt := range tests {
+		err := tt.fn()
+		if err == nil {
+			t.Errorf("%d: got nil, want error", tn)
+			continue
+		}
+		if got, want := err.Error(), tt.want; got != want {
+			t.Errorf("%d:\n got=%v\nwant=%v", tn, got, want)
+		}
+	}
+
+	err := Safe(func() error { panic(panicStruct{1, 2}) })
+	if got, want := Fields(err).Get("panic"), (panicStruct{1, 2}); got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+}
+
+func TestRecoverStack(t *testing.T) {
+	err := Safe(func() error {
+		panic("boom")
+	})
+	st := err.(interface{ StackTrace() StackTrace }).StackTrace()
+	if len(st) == 0 {
+		t.Fatal("want stack trace, got none")
+	}
+	if got, want := fmt.Sprintf("%n", st[0]), "TestRecoverStack.func1"; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+}
+
+func TestRepanic(t *testing.T) {
+	defer func(repanic bool) { Repanic = repanic }(Repanic)
+	Repanic = true
+
+	var err error
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("got=%v, want=boom", r)
+			}
+		}()
+		defer Recover(&err)
+		panic("boom")
+	}()
+	if got, want := fmt.Sprint(err), "panic panic=boom"; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+}
@@ -0,0 +1,63 @@
+package kv
+
+import (
+	"regexp"
+
+	"github.com/jjeffery/kv/internal/redact"
+)
+
+// Patterns for values that are commonly redacted. See RedactValues.
+var (
+	// BearerTokenPattern matches bearer tokens, such as those found
+	// in HTTP authorization headers.
+	BearerTokenPattern = regexp.MustCompile(`(?i)\bbearer\s+[a-z0-9\-._~+/]+=*`)
+
+	// CardNumberPattern matches payment card numbers: 13 to 19 digits,
+	// optionally separated by spaces or hyphens.
+	CardNumberPattern = regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`)
+)
+
+// RedactKeys registers key patterns for values that should never
+// appear in rendered output. Whenever a key/value pair is rendered
+// and the key matches one of the patterns, the value is replaced with
+// "[REDACTED]". This applies to lists, contexts and errors, and to
+// log messages handled by the kvlog package.
+//
+// A pattern is either an exact key name or a glob pattern in the
+// form accepted by path.Match, eg "*password*". Keys are matched
+// without regard to case. Invalid glob patterns only match keys
+// with exactly the same name.
+//
+// Redaction rules are typically registered during program
+// initialization, but it is safe to register them at any time.
+func RedactKeys(patterns ...string) {
+	redact.AddKeys(patterns...)
+}
+
+// RedactKeyRegexp registers a regular expression for keys whose values
+// should never appear in rendered output. See RedactKeys.
+func RedactKeyRegexp(re *regexp.Regexp) {
+	redact.AddKeyRegexp(re)
+}
+
+// RedactValues registers regular expressions for values that should
+// never appear in rendered output. When a key/value pair is rendered
+// and any part of the value matches one of the regular expressions, the
+// value is replaced with "[REDACTED]".
+//
+// BearerTokenPattern and CardNumberPattern are provided as examples
+// of commonly redacted values.
+func RedactValues(res ...*regexp.Regexp) {
+	for _, re := range res {
+		redact.AddValueRegexp(re)
+	}
+}
+
+// RedactHashKey sets a secret key for hashing redacted values. If the
+// key is not empty, redacted values are replaced with a keyed hash of
+// the value, eg "[REDACTED:1f2e3d4c5b6a7988]". This makes it possible to
+// tell whether two redacted values are the same, without revealing them.
+// If the key is empty, redacted values are replaced with "[REDACTED]".
+func RedactHashKey(key []byte) {
+	redact.SetHashKey(key)
+}
@@ -0,0 +1,133 @@
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/jjeffery/kv/internal/redact"
+)
+
+func TestRedact(t *testing.T) {
+	defer redact.Reset()
+	RedactKeys("password", "*secret*")
+	RedactValues(BearerTokenPattern, CardNumberPattern)
+
+	tests := []struct {
+		fn   func() interface{}
+		want string
+	}{
+		{
+			fn: func() interface{} {
+				return With("user", "alice", "password", "p@ssw0rd")
+			},
+			want: `user=alice password="[REDACTED]"`,
+		},
+		{
+			fn: func() interface{} {
+				return With("client_secret", 1234, "auth", "Bearer eyJhbGciOi.eyJzdWIiOi.SflKxw")
+			},
+			want: `client_secret="[REDACTED]" auth="[REDACTED]"`,
+		},
+		{
+			fn: func() interface{} {
+				return With("msg", "card 4111 1111 1111 1111 declined", "amount", 100)
+			},
+			want: `msg="[REDACTED]" amount=100`,
+		},
+		{
+			fn: func() interface{} {
+				return NewError("login failed").With("user", "alice", "password", "p")
+			},
+			want: `login failed user=alice password="[REDACTED]"`,
+		},
+		{
+			fn: func() interface{} {
+				err := NewError("login failed").With("password", "p")
+				return Wrap(err, "cannot authenticate")
+			},
+			want: `cannot authenticate: login failed password="[REDACTED]"`,
+		},
+		{
+			fn: func() interface{} {
+				return From(context.Background()).With("password", "p")
+			},
+			want: `password="[REDACTED]"`,
+		},
+	}
+	for tn, tt := range tests {
+		if got, want := fmt.Sprint(tt.fn()), tt.want; got != want {
+			t.Errorf("%d:\n got=%v\nwant=%v", tn, got, want)
+		}
+	}
+
+	RedactHashKey([]byte("hash key"))
+	s1 := With("password", "p1").String()
+	s2 := With("password", "p1").String()
+	s3 := With("password", "p2").String()
+	if s1 != s2 || s1 == s3 {
+		t.Errorf("unexpected hashes: %s, %s, %s", s1, s2, s3)
+	}
+}
+
+func TestRedactJSON(t *testing.T) {
+	defer redact.Reset()
+	RedactKeys("password")
+	RedactValues(BearerTokenPattern)
+
+	tests := []struct {
+		list List
+		want string
+	}{
+		{
+			list: With("user", "alice", "password", "hunter2"),
+			want: `{"user":"alice","password":"[REDACTED]"}`,
+		},
+		{
+			list: With("password", "p1", "password", "p2"),
+			want: `{"password":["[REDACTED]","[REDACTED]"]}`,
+		},
+		{
+			list: With("auth", "Bearer eyJhbGciOi.eyJzdWIiOi.SflKxw", "n", 1),
+			want: `{"auth":"[REDACTED]","n":1}`,
+		},
+	}
+	for tn, tt := range tests {
+		b, err := json.Marshal(tt.list)
+		if err != nil {
+			t.Errorf("%d: %v", tn, err)
+			continue
+		}
+		if got, want := string(b), tt.want; got != want {
+			t.Errorf("%d:\n got=%v\nwant=%v", tn, got, want)
+		}
+	}
+}
+
+func TestRedactErrorJSON(t *testing.T) {
+	defer redact.Reset()
+	RedactKeys("password")
+
+	err := Wrap(NewError("x").With("password", "hunter2"), "login failed").With("token", "abc", "password", "p2")
+	b, jsonErr := json.Marshal(err)
+	if jsonErr != nil {
+		t.Fatal(jsonErr)
+	}
+	want := `{"msg":"login failed","fields":{"token":"abc","password":"[REDACTED]"},` +
+		`"cause":{"msg":"x","fields":{"password":"[REDACTED]"}}}`
+	if got := string(b); got != want {
+		t.Errorf("\n got=%v\nwant=%v", got, want)
+	}
+}
+
+func TestRedactBypass(t *testing.T) {
+	defer redact.Reset()
+	RedactKeys("password")
+
+	for _, value := range []string{"[REDACTEDhunter2", "[REDACTED]hunter2", "[REDACTED:hunter2]"} {
+		if got, want := With("password", value).String(), `password="[REDACTED]"`; got != want {
+			t.Errorf("%s:\n got=%v\nwant=%v", value, got, want)
+		}
+	}
+}
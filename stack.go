@@ -0,0 +1,139 @@
+package kv
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// CaptureStacks determines whether a stack trace is captured when an
+// error is created by NewError, Wrap, List.NewError, List.Wrap,
+// Context.NewError or Context.Wrap. It is false by default, because
+// capturing a stack trace is relatively expensive.
+//
+// A stack trace can be captured for an individual error, regardless of this
+// setting, by calling its WithStack method.
+//
+// CaptureStacks should only be modified during program initialization.
+var CaptureStacks = false
+
+// maxStackDepth is the maximum number of frames captured in a stack trace.
+const maxStackDepth = 32
+
+// Frame represents a program counter inside a stack frame.
+// It mimics the Frame type in github.com/pkg/errors.
+type Frame uintptr
+
+// pc returns the program counter for this frame. The program
+// counter is one past the call instruction.
+func (f Frame) pc() uintptr { return uintptr(f) - 1 }
+
+// location returns the function name, file and line for the frame.
+func (f Frame) location() (name string, file string, line int) {
+	fn := runtime.FuncForPC(f.pc())
+	if fn == nil {
+		return "unknown", "unknown", 0
+	}
+	file, line = fn.FileLine(f.pc())
+	return fn.Name(), file, line
+}
+
+// Format formats the frame according to the fmt.Formatter interface.
+//
+//	%s    source file
+//	%d    source line
+//	%n    function name
+//	%v    equivalent to %s:%d
+//
+// Format accepts flags that alter the printing of some verbs, as follows:
+//
+//	%+s   function name and path of source file relative to the compile time
+//	      GOPATH separated by \n\t (<funcname>\n\t<path>)
+//	%+v   equivalent to %+s:%d
+func (f Frame) Format(s fmt.State, verb rune) {
+	name, file, line := f.location()
+	switch verb {
+	case 's':
+		if s.Flag('+') {
+			io.WriteString(s, name)
+			io.WriteString(s, "\n\t")
+			io.WriteString(s, file)
+		} else {
+			io.WriteString(s, path.Base(file))
+		}
+	case 'd':
+		io.WriteString(s, strconv.Itoa(line))
+	case 'n':
+		io.WriteString(s, funcName(name))
+	case 'v':
+		f.Format(s, 's')
+		io.WriteString(s, ":")
+		f.Format(s, 'd')
+	}
+}
+
+// StackTrace is a stack of frames from innermost (newest) to outermost (oldest).
+// It mimics the StackTrace type in github.com/pkg/errors.
+type StackTrace []Frame
+
+// Format formats the stack of frames according to the fmt.Formatter interface.
+//
+//	%s	lists source files for each frame in the stack
+//	%v	lists the source file and line number for each frame in the stack
+//
+// Format accepts flags that alter the printing of some verbs, as follows:
+//
+//	%+v   prints filename, function, and line number for each frame in the stack.
+func (st StackTrace) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			for _, f := range st {
+				io.WriteString(s, "\n")
+				f.Format(s, verb)
+			}
+			return
+		}
+		fallthrough
+	case 's':
+		io.WriteString(s, "[")
+		for i, f := range st {
+			if i > 0 {
+				io.WriteString(s, " ")
+			}
+			f.Format(s, verb)
+		}
+		io.WriteString(s, "]")
+	}
+}
+
+// callers returns the program counters of the calling goroutine's stack,
+// skipping the number of frames specified.
+func callers(skip int) []uintptr {
+	var pcs [maxStackDepth]uintptr
+	n := runtime.Callers(skip, pcs[:])
+	return append([]uintptr(nil), pcs[:n]...)
+}
+
+// stackTrace converts program counters into a StackTrace.
+func stackTrace(pcs []uintptr) StackTrace {
+	if len(pcs) == 0 {
+		return nil
+	}
+	st := make(StackTrace, len(pcs))
+	for i, pc := range pcs {
+		st[i] = Frame(pc)
+	}
+	return st
+}
+
+// funcName removes the path prefix component of a function's name.
+func funcName(name string) string {
+	i := strings.LastIndex(name, "/")
+	name = name[i+1:]
+	i = strings.Index(name, ".")
+	return name[i+1:]
+}
@@ -0,0 +1,125 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestStackTrace(t *testing.T) {
+	defer func(capture bool) { CaptureStacks = capture }(CaptureStacks)
+	CaptureStacks = true
+	ctx := From(context.Background())
+
+	tests := []struct {
+		fn   func() Error
+		want string
+	}{
+		{
+			fn:   func() Error { return NewError("text") },
+			want: "kv.TestStackTrace.func",
+		},
+		{
+			fn:   func() Error { return Wrap(errors.New("text")) },
+			want: "kv.TestStackTrace.func",
+		},
+		{
+			fn:   func() Error { return List{"a", 1}.NewError("text") },
+			want: "kv.TestStackTrace.func",
+		},
+		{
+			fn:   func() Error { return List{"a", 1}.Wrap(errors.New("text")) },
+			want: "kv.TestStackTrace.func",
+		},
+		{
+			fn:   func() Error { return ctx.NewError("text") },
+			want: "kv.TestStackTrace.func",
+		},
+		{
+			fn:   func() Error { return ctx.Wrap(errors.New("text")) },
+			want: "kv.TestStackTrace.func",
+		},
+		{
+			fn:   func() Error { return NewError("text").With("a", 1) },
+			want: "kv.TestStackTrace.func",
+		},
+	}
+	for tn, tt := range tests {
+		err := tt.fn()
+		st := err.(interface{ StackTrace() StackTrace }).StackTrace()
+		if len(st) == 0 {
+			t.Errorf("%d: no stack trace", tn)
+			continue
+		}
+		if got, want := fmt.Sprintf("%+s", st[0]), tt.want; !strings.Contains(got, want) {
+			t.Errorf("%d:\n got=%v\nwant=%v", tn, got, want)
+		}
+	}
+}
+
+func TestWithStack(t *testing.T) {
+	err := NewError("text").With("a", 1)
+	if st := err.(interface{ StackTrace() StackTrace }).StackTrace(); st != nil {
+		t.Fatalf("want no stack trace, got %v", st)
+	}
+	err = err.WithStack()
+	st := err.(interface{ StackTrace() StackTrace }).StackTrace()
+	if len(st) == 0 {
+		t.Fatal("want stack trace, got none")
+	}
+	if got, want := fmt.Sprintf("%n", st[0]), "TestWithStack"; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+	if got, want := fmt.Sprintf("%s", st[0]), "stack_test.go"; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+	if got, want := err.Error(), "text a=1"; got != want {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+}
+
+func TestFormatStack(t *testing.T) {
+	err1 := NewError("first").WithStack()
+	err2 := Wrap(err1, "second").With("a", 1).WithStack()
+
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{
+			format: "%s",
+			want:   []string{"second: first a=1"},
+		},
+		{
+			format: "%v",
+			want:   []string{"second: first a=1"},
+		},
+		{
+			format: "%q",
+			want:   []string{`"second: first a=1"`},
+		},
+		{
+			format: "%+v",
+			want: []string{
+				"second: first a=1\n",
+				"kv.TestFormatStack\n\t",
+				"stack_test.go:",
+			},
+		},
+	}
+	for tn, tt := range tests {
+		got := fmt.Sprintf(tt.format, err2)
+		for _, want := range tt.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("%d:\n got=%v\nwant=%v", tn, got, want)
+			}
+		}
+		if tt.format == "%+v" {
+			if got, want := strings.Count(got, "kv.TestFormatStack\n"), 2; got != want {
+				t.Errorf("%d: got %d stack traces, want %d", tn, got, want)
+			}
+		}
+	}
+}